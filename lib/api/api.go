@@ -0,0 +1,340 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/controller"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+// Api manages the on-incident handlers with the same database methods as the kafka HANDLER command
+//
+//	GET    /on-incident-handlers?tenant_id=&process_definition_id=&limit=&offset=
+//	GET    /on-incident-handlers/{id}
+//	PUT    /on-incident-handlers/{id}
+//	DELETE /on-incident-handlers/{id}
+//
+// {id} is the process-definition id of the handler; tenants may only put handlers of process-definitions they have deployed
+type Api struct {
+	db      interfaces.Database
+	camunda interfaces.Camunda
+	auth    *Auth
+	router  *http.ServeMux
+	server  *http.Server
+}
+
+// Start serves the api on config.AdminApiPort until ctx is done; the result is nil if the api is disabled
+func Start(ctx context.Context, config configuration.Config, db interfaces.Database, camunda interfaces.Camunda) (*Api, error) {
+	if config.AdminApiPort == "" || config.AdminApiPort == "-" {
+		return nil, nil
+	}
+	api, err := New(config, db, camunda)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Addr: ":" + config.AdminApiPort, Handler: api}
+	api.server = server
+	go func() {
+		log.Println("admin api listening on ", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			debug.PrintStack()
+			log.Fatal("FATAL:", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		log.Println("admin api shutdown", server.Shutdown(context.Background()))
+	}()
+	return api, nil
+}
+
+func New(config configuration.Config, db interfaces.Database, camunda interfaces.Camunda) (*Api, error) {
+	auth, err := NewAuth(config)
+	if err != nil {
+		return nil, err
+	}
+	api := &Api{db: db, camunda: camunda, auth: auth, router: http.NewServeMux()}
+	api.router.HandleFunc("GET /on-incident-handlers", api.listHandlers)
+	api.router.HandleFunc("GET /on-incident-handlers/{id}", api.getHandler)
+	api.router.HandleFunc("PUT /on-incident-handlers/{id}", api.putHandler)
+	api.router.HandleFunc("DELETE /on-incident-handlers/{id}", api.deleteHandler)
+	return api, nil
+}
+
+func (this *Api) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	this.router.ServeHTTP(writer, request)
+}
+
+// Close shuts the server of Start down; the server is also shut down when the ctx of Start is done
+func (this *Api) Close() error {
+	if this == nil || this.server == nil {
+		return nil
+	}
+	log.Println("admin api shutdown")
+	return this.server.Shutdown(context.Background())
+}
+
+// allows checks if access includes the handler. handlers without tenant (e.g. saved by the kafka HANDLER command)
+// belong to the tenant that has deployed the process-definition and are assigned to it on the first access
+func (this *Api) allows(ctx context.Context, access Access, handler messages.OnIncident) (bool, error) {
+	if access.Admin {
+		return true, nil
+	}
+	if handler.TenantId != "" {
+		return handler.TenantId == access.TenantId, nil
+	}
+	owner, err := this.camunda.IsProcessDefinitionOwner(ctx, handler.ProcessDefinitionId, access.TenantId)
+	if err != nil || !owner {
+		return false, err
+	}
+	_, err = this.db.SetOnIncidentTenantId(handler.ProcessDefinitionId, access.TenantId)
+	return err == nil, err
+}
+
+// assignTenantHandlers assigns the handlers without tenant to the tenant, if it has deployed their process-definitions
+func (this *Api) assignTenantHandlers(ctx context.Context, tenantId string) error {
+	handlers, err := this.db.ListOnIncidents(interfaces.OnIncidentQuery{WithoutTenant: true})
+	if err != nil || len(handlers) == 0 {
+		return err
+	}
+	definitionIds, err := this.camunda.ListProcessDefinitionIds(ctx, tenantId)
+	if err != nil {
+		return err
+	}
+	deployed := map[string]bool{}
+	for _, id := range definitionIds {
+		deployed[id] = true
+	}
+	for _, handler := range handlers {
+		if deployed[handler.ProcessDefinitionId] {
+			_, err = this.db.SetOnIncidentTenantId(handler.ProcessDefinitionId, tenantId)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (this *Api) listHandlers(writer http.ResponseWriter, request *http.Request) {
+	access, ok := this.getAccess(writer, request)
+	if !ok {
+		return
+	}
+	query := interfaces.OnIncidentQuery{
+		TenantId:            request.URL.Query().Get("tenant_id"),
+		ProcessDefinitionId: request.URL.Query().Get("process_definition_id"),
+	}
+	if !access.Admin {
+		if query.TenantId != "" && query.TenantId != access.TenantId {
+			http.Error(writer, "access to handlers of tenant "+query.TenantId+" denied", http.StatusForbidden)
+			return
+		}
+		query.TenantId = access.TenantId
+		err := this.assignTenantHandlers(request.Context(), access.TenantId)
+		if err != nil {
+			this.camundaError(writer, err)
+			return
+		}
+	}
+	var err error
+	for _, param := range []struct {
+		name   string
+		target *int64
+	}{{name: "limit", target: &query.Limit}, {name: "offset", target: &query.Offset}} {
+		if value := request.URL.Query().Get(param.name); value != "" {
+			*param.target, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || *param.target < 0 {
+				http.Error(writer, "invalid "+param.name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	handlers, err := this.db.ListOnIncidents(query)
+	if err != nil {
+		this.internalError(writer, err)
+		return
+	}
+	this.writeJson(writer, http.StatusOK, handlers)
+}
+
+func (this *Api) getHandler(writer http.ResponseWriter, request *http.Request) {
+	access, ok := this.getAccess(writer, request)
+	if !ok {
+		return
+	}
+	handler, exists, err := this.db.GetOnIncident(request.PathValue("id"))
+	if err != nil {
+		this.internalError(writer, err)
+		return
+	}
+	if !exists {
+		http.Error(writer, "handler not found", http.StatusNotFound)
+		return
+	}
+	allowed, err := this.allows(request.Context(), access, handler)
+	if err != nil {
+		this.camundaError(writer, err)
+		return
+	}
+	if !allowed {
+		http.Error(writer, "handler not found", http.StatusNotFound)
+		return
+	}
+	if handler.TenantId == "" && !access.Admin {
+		handler.TenantId = access.TenantId
+	}
+	this.writeJson(writer, http.StatusOK, handler)
+}
+
+// putHandler saves the handler like the kafka HANDLER command; the restart state of an existing handler is reset
+func (this *Api) putHandler(writer http.ResponseWriter, request *http.Request) {
+	access, ok := this.getAccess(writer, request)
+	if !ok {
+		return
+	}
+	id := request.PathValue("id")
+	handler := messages.OnIncident{}
+	err := json.NewDecoder(request.Body).Decode(&handler)
+	if err != nil {
+		http.Error(writer, "invalid handler: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if handler.ProcessDefinitionId == "" {
+		handler.ProcessDefinitionId = id
+	}
+	if handler.ProcessDefinitionId != id {
+		http.Error(writer, "process_definition_id does not match the path", http.StatusBadRequest)
+		return
+	}
+	existing, exists, err := this.db.GetOnIncident(id)
+	if err != nil {
+		this.internalError(writer, err)
+		return
+	}
+	if exists && existing.TenantId != "" && !access.Admin && existing.TenantId != access.TenantId {
+		http.Error(writer, "handler belongs to another tenant", http.StatusForbidden)
+		return
+	}
+	if !access.Admin {
+		//handlers without tenant belong to the tenant that has deployed the process-definition
+		owner, err := this.camunda.IsProcessDefinitionOwner(request.Context(), id, access.TenantId)
+		if err != nil {
+			this.camundaError(writer, err)
+			return
+		}
+		if !owner {
+			http.Error(writer, "process-definition not found", http.StatusNotFound)
+			return
+		}
+	}
+	if !access.Admin {
+		handler.TenantId = access.TenantId
+	} else if handler.TenantId == "" {
+		handler.TenantId = existing.TenantId
+	}
+	handler.RestartState = nil
+	err = controller.ValidateOnIncident(handler)
+	if err != nil {
+		http.Error(writer, "invalid handler: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = this.db.SaveOnIncident(handler)
+	if err != nil {
+		this.internalError(writer, err)
+		return
+	}
+	this.writeJson(writer, http.StatusOK, handler)
+}
+
+func (this *Api) deleteHandler(writer http.ResponseWriter, request *http.Request) {
+	access, ok := this.getAccess(writer, request)
+	if !ok {
+		return
+	}
+	id := request.PathValue("id")
+	handler, exists, err := this.db.GetOnIncident(id)
+	if err != nil {
+		this.internalError(writer, err)
+		return
+	}
+	if !exists {
+		http.Error(writer, "handler not found", http.StatusNotFound)
+		return
+	}
+	allowed, err := this.allows(request.Context(), access, handler)
+	if err != nil {
+		this.camundaError(writer, err)
+		return
+	}
+	if !allowed {
+		http.Error(writer, "handler not found", http.StatusNotFound)
+		return
+	}
+	err = this.db.DeleteOnIncidentByDefinitionId(id)
+	if err != nil {
+		this.internalError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (this *Api) getAccess(writer http.ResponseWriter, request *http.Request) (access Access, ok bool) {
+	access, err := this.auth.GetAccess(request)
+	if errors.Is(err, ErrMissingAuth) {
+		writer.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(writer, err.Error(), http.StatusUnauthorized)
+		return access, false
+	}
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusUnauthorized)
+		return access, false
+	}
+	return access, true
+}
+
+// camundaError responds with 503 if the camunda shard of the tenant is unavailable
+func (this *Api) camundaError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, interfaces.ErrShardUnavailable) {
+		http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	this.internalError(writer, err)
+}
+
+func (this *Api) internalError(writer http.ResponseWriter, err error) {
+	log.Println("ERROR: admin api:", err)
+	http.Error(writer, err.Error(), http.StatusInternalServerError)
+}
+
+func (this *Api) writeJson(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(status)
+	err := json.NewEncoder(writer).Encode(value)
+	if err != nil {
+		log.Println("ERROR: unable to write admin api response:", err)
+	}
+}
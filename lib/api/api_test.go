@@ -0,0 +1,310 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type apiTestDb struct {
+	interfaces.Database
+	mux      sync.Mutex
+	handlers map[string]messages.OnIncident
+}
+
+func (this *apiTestDb) SaveOnIncident(handler messages.OnIncident) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.handlers[handler.ProcessDefinitionId] = handler
+	return nil
+}
+
+func (this *apiTestDb) GetOnIncident(definitionId string) (messages.OnIncident, bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	handler, ok := this.handlers[definitionId]
+	return handler, ok, nil
+}
+
+func (this *apiTestDb) DeleteOnIncidentByDefinitionId(definitionId string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.handlers, definitionId)
+	return nil
+}
+
+func (this *apiTestDb) SetOnIncidentTenantId(definitionId string, tenantId string) (bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	handler, ok := this.handlers[definitionId]
+	if !ok || handler.TenantId != "" {
+		return false, nil
+	}
+	handler.TenantId = tenantId
+	this.handlers[definitionId] = handler
+	return true, nil
+}
+
+func (this *apiTestDb) ListOnIncidents(query interfaces.OnIncidentQuery) (result []messages.OnIncident, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []messages.OnIncident{}
+	for _, handler := range this.handlers {
+		if query.WithoutTenant && handler.TenantId != "" {
+			continue
+		}
+		if (query.WithoutTenant || query.TenantId == "" || query.TenantId == handler.TenantId) && (query.ProcessDefinitionId == "" || query.ProcessDefinitionId == handler.ProcessDefinitionId) {
+			result = append(result, handler)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProcessDefinitionId < result[j].ProcessDefinitionId
+	})
+	return result, nil
+}
+
+type apiTestCamunda struct {
+	interfaces.Camunda
+	owners map[string]string //process-definition id -> tenant id
+}
+
+func (this *apiTestCamunda) IsProcessDefinitionOwner(ctx context.Context, id string, tenantId string) (bool, error) {
+	return this.owners[id] == tenantId, nil
+}
+
+func (this *apiTestCamunda) ListProcessDefinitionIds(ctx context.Context, tenantId string) (result []string, err error) {
+	for id, owner := range this.owners {
+		if owner == tenantId {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+func apiTestRequest(t *testing.T, api *Api, method string, path string, token string, body string) (status int, respBody string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	api.ServeHTTP(resp, req)
+	return resp.Code, resp.Body.String()
+}
+
+// apiTestKey returns a new rsa key and its pem encoded public key
+func apiTestKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+}
+
+func TestApi(t *testing.T) {
+	db := &apiTestDb{handlers: map[string]messages.OnIncident{
+		"kafka-handler": {ProcessDefinitionId: "kafka-handler", Notify: true},
+	}}
+	key, publicKey := apiTestKey(t)
+	camunda := &apiTestCamunda{owners: map[string]string{"d1": "user1", "d2": "user2"}}
+	api, err := New(configuration.Config{AdminApiToken: "admin-secret", AdminApiJwtTenantClaim: "sub", AdminApiJwtPublicKey: publicKey}, db, camunda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user1, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user1"}).SignedString(key)
+	user2, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user2"}).SignedString(key)
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user1", "exp": time.Now().Add(-time.Minute).Unix()}).SignedString(key)
+
+	if status, _ := apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers", "", ""); status != http.StatusUnauthorized {
+		t.Fatal(status)
+	}
+	if status, _ := apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers", "wrong", ""); status != http.StatusUnauthorized {
+		t.Fatal(status)
+	}
+	if status, _ := apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers", expired, ""); status != http.StatusUnauthorized {
+		t.Fatal(status)
+	}
+
+	//tenants own the handlers they put
+	status, body := apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/d1", user1, `{"restart": true, "tenant_id": "user2", "restart_state": {"disabled": true}}`)
+	if status != http.StatusOK || db.handlers["d1"].TenantId != "user1" || !db.handlers["d1"].Restart || db.handlers["d1"].RestartState != nil {
+		t.Fatal(status, body, db.handlers["d1"])
+	}
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/d1", user2, `{"notify": true}`); status != http.StatusForbidden {
+		t.Fatal(status, body)
+	}
+	//tenants may only put handlers of their own process-definitions
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/d2", user1, `{"notify": true}`); status != http.StatusNotFound || len(db.handlers) != 2 {
+		t.Fatal(status, body, db.handlers)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/unknown", user1, `{"notify": true}`); status != http.StatusNotFound || len(db.handlers) != 2 {
+		t.Fatal(status, body, db.handlers)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/d1", user1, `{"process_definition_id": "d2"}`); status != http.StatusBadRequest {
+		t.Fatal(status, body)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/d1", user1, `{"mode": "unknown"}`); status != http.StatusBadRequest {
+		t.Fatal(status, body)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers/d1", user2, ""); status != http.StatusNotFound {
+		t.Fatal(status, body)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers/kafka-handler", user1, ""); status != http.StatusNotFound {
+		t.Fatal(status, body)
+	}
+	status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers/d1", user1, "")
+	handler := messages.OnIncident{}
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &handler) != nil || handler.ProcessDefinitionId != "d1" {
+		t.Fatal(status, body)
+	}
+
+	//tenants only list their own handlers
+	status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers", user1, "")
+	handlers := []messages.OnIncident{}
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &handlers) != nil || len(handlers) != 1 {
+		t.Fatal(status, body)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers?tenant_id=user2", user1, ""); status != http.StatusForbidden {
+		t.Fatal(status, body)
+	}
+	status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers", "admin-secret", "")
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &handlers) != nil || len(handlers) != 2 {
+		t.Fatal(status, body)
+	}
+	status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers?tenant_id=user1&process_definition_id=d1", "admin-secret", "")
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &handlers) != nil || len(handlers) != 1 {
+		t.Fatal(status, body)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers?limit=x", "admin-secret", ""); status != http.StatusBadRequest {
+		t.Fatal(status, body)
+	}
+
+	//admins keep the tenant of existing handlers
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/d1", "admin-secret", `{"notify": true}`); status != http.StatusOK || db.handlers["d1"].TenantId != "user1" {
+		t.Fatal(status, body, db.handlers["d1"])
+	}
+
+	if status, body = apiTestRequest(t, api, http.MethodDelete, "/on-incident-handlers/d1", user2, ""); status != http.StatusNotFound {
+		t.Fatal(status, body)
+	}
+	if status, body = apiTestRequest(t, api, http.MethodDelete, "/on-incident-handlers/d1", user1, ""); status != http.StatusNoContent || len(db.handlers) != 1 {
+		t.Fatal(status, body, db.handlers)
+	}
+}
+
+func TestApiHandlersWithoutTenant(t *testing.T) {
+	//saved by the kafka HANDLER command
+	db := &apiTestDb{handlers: map[string]messages.OnIncident{
+		"k1": {ProcessDefinitionId: "k1", Notify: true},
+		"k2": {ProcessDefinitionId: "k2", Notify: true},
+		"k3": {ProcessDefinitionId: "k3", Notify: true},
+	}}
+	key, publicKey := apiTestKey(t)
+	camunda := &apiTestCamunda{owners: map[string]string{"k1": "user1", "k2": "user1", "k3": "user2"}}
+	api, err := New(configuration.Config{AdminApiToken: "admin-secret", AdminApiJwtTenantClaim: "sub", AdminApiJwtPublicKey: publicKey}, db, camunda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user1, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user1"}).SignedString(key)
+	user2, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user2"}).SignedString(key)
+
+	if status, body := apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers/k1", user2, ""); status != http.StatusNotFound || db.handlers["k1"].TenantId != "" {
+		t.Fatal(status, body, db.handlers["k1"])
+	}
+	status, body := apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers/k1", user1, "")
+	handler := messages.OnIncident{}
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &handler) != nil || handler.TenantId != "user1" || db.handlers["k1"].TenantId != "user1" {
+		t.Fatal(status, body, db.handlers["k1"])
+	}
+
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/k2", user2, `{"notify": true}`); status != http.StatusNotFound || db.handlers["k2"].TenantId != "" {
+		t.Fatal(status, body, db.handlers["k2"])
+	}
+	if status, body = apiTestRequest(t, api, http.MethodPut, "/on-incident-handlers/k2", user1, `{"restart": true}`); status != http.StatusOK || db.handlers["k2"].TenantId != "user1" || !db.handlers["k2"].Restart {
+		t.Fatal(status, body, db.handlers["k2"])
+	}
+
+	if status, body = apiTestRequest(t, api, http.MethodDelete, "/on-incident-handlers/k3", user1, ""); status != http.StatusNotFound || len(db.handlers) != 3 {
+		t.Fatal(status, body, db.handlers)
+	}
+
+	//tenants list the handlers of their process-definitions
+	status, body = apiTestRequest(t, api, http.MethodGet, "/on-incident-handlers", user2, "")
+	handlers := []messages.OnIncident{}
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &handlers) != nil || len(handlers) != 1 || handlers[0].ProcessDefinitionId != "k3" || db.handlers["k3"].TenantId != "user2" {
+		t.Fatal(status, body)
+	}
+
+	if status, body = apiTestRequest(t, api, http.MethodDelete, "/on-incident-handlers/k3", user2, ""); status != http.StatusNoContent || len(db.handlers) != 2 {
+		t.Fatal(status, body, db.handlers)
+	}
+}
+
+func TestVerifiedJwt(t *testing.T) {
+	key, publicKey := apiTestKey(t)
+	auth, err := NewAuth(configuration.Config{
+		AdminApiJwtTenantClaim: "tenant",
+		AdminApiJwtPublicKey:   publicKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"tenant": "user1"}).SignedString(key)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"tenant": "user1"}).SignedString([]byte("forged"))
+
+	req := httptest.NewRequest(http.MethodGet, "/on-incident-handlers", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	access, err := auth.GetAccess(req)
+	if err != nil || access.Admin || access.TenantId != "user1" {
+		t.Fatal(err, access)
+	}
+	req.Header.Set("Authorization", "Bearer "+forged)
+	_, err = auth.GetAccess(req)
+	if err == nil {
+		t.Fatal("expected error for forged jwt")
+	}
+
+	_, err = NewAuth(configuration.Config{})
+	if err == nil {
+		t.Fatal("expected error without token and claim")
+	}
+	_, err = NewAuth(configuration.Config{AdminApiToken: "admin-secret", AdminApiJwtTenantClaim: "tenant"})
+	if err == nil {
+		t.Fatal("expected error for tenant claim without key")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strings"
+)
+
+var ErrMissingAuth = errors.New("missing bearer token")
+var ErrInvalidAuth = errors.New("invalid bearer token")
+
+// Access of a request; admins may access the handlers of all tenants, otherwise only the handlers of TenantId
+type Access struct {
+	Admin    bool
+	TenantId string
+}
+
+type Auth struct {
+	token       string
+	tenantClaim string
+	key         crypto.PublicKey //verifies the jwts of tenants
+}
+
+func NewAuth(config configuration.Config) (auth *Auth, err error) {
+	if config.AdminApiToken == "" && config.AdminApiJwtTenantClaim == "" {
+		return nil, errors.New("admin api needs admin_api_token or admin_api_jwt_tenant_claim")
+	}
+	if config.AdminApiJwtTenantClaim != "" && config.AdminApiJwtPublicKey == "" {
+		return nil, errors.New("admin_api_jwt_tenant_claim needs admin_api_jwt_public_key to verify jwts")
+	}
+	auth = &Auth{token: config.AdminApiToken, tenantClaim: config.AdminApiJwtTenantClaim}
+	if config.AdminApiJwtPublicKey != "" {
+		auth.key, err = jwt.ParseRSAPublicKeyFromPEM([]byte(config.AdminApiJwtPublicKey))
+		if err != nil {
+			auth.key, err = jwt.ParseECPublicKeyFromPEM([]byte(config.AdminApiJwtPublicKey))
+		}
+		if err != nil {
+			return nil, errors.New("invalid admin_api_jwt_public_key: " + err.Error())
+		}
+	}
+	return auth, nil
+}
+
+// GetAccess checks the bearer token of the request against the admin token and the jwt tenant claim
+func (this *Auth) GetAccess(request *http.Request) (access Access, err error) {
+	token := request.Header.Get("Authorization")
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = token[7:]
+	} else {
+		return access, ErrMissingAuth
+	}
+	if this.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(this.token)) == 1 {
+		return Access{Admin: true}, nil
+	}
+	if this.tenantClaim == "" {
+		return access, ErrInvalidAuth
+	}
+	claims, err := this.parseJwt(token)
+	if err != nil {
+		return access, errors.Join(ErrInvalidAuth, err)
+	}
+	tenantId, _ := claims[this.tenantClaim].(string)
+	if tenantId == "" {
+		return access, errors.Join(ErrInvalidAuth, errors.New("missing claim "+this.tenantClaim))
+	}
+	return Access{TenantId: tenantId}, nil
+}
+
+// parseJwt verifies the signature and the expiration of the token
+func (this *Auth) parseJwt(token string) (claims jwt.MapClaims, err error) {
+	claims = jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return this.key, nil
+	}, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}))
+	return claims, err
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open" //one probe call is allowed
+)
+
+const BreakerProbeWait = time.Second //wait of other calls while the probe call of a half-open breaker is running
+
+// CircuitBreaker opens after threshold consecutive failures and rejects calls for openDuration;
+// afterward a single probe call decides if the breaker is closed again
+type CircuitBreaker struct {
+	mux          sync.Mutex
+	threshold    int
+	openDuration time.Duration
+	failures     int
+	openUntil    time.Time
+	probing      bool
+}
+
+// NewCircuitBreaker returns a breaker that is never opened if threshold < 1
+func NewCircuitBreaker(threshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, openDuration: openDuration}
+}
+
+// Allow returns false and the time of the next possible call while the breaker is open.
+// every allowed call must be followed by Done
+func (this *CircuitBreaker) Allow() (ok bool, retryAt time.Time) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.threshold < 1 || this.failures < this.threshold {
+		return true, time.Time{}
+	}
+	now := time.Now()
+	if now.Before(this.openUntil) {
+		return false, this.openUntil
+	}
+	if this.probing {
+		return false, now.Add(BreakerProbeWait)
+	}
+	this.probing = true
+	return true, time.Time{}
+}
+
+func (this *CircuitBreaker) Done(success bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.probing = false
+	if success {
+		this.failures = 0
+		return
+	}
+	this.failures++
+	if this.threshold > 0 && this.failures >= this.threshold {
+		this.openUntil = time.Now().Add(this.openDuration)
+	}
+}
+
+// State returns one of BreakerState...
+func (this *CircuitBreaker) State() string {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.threshold < 1 || this.failures < this.threshold {
+		return BreakerStateClosed
+	}
+	if time.Now().Before(this.openUntil) {
+		return BreakerStateOpen
+	}
+	return BreakerStateHalfOpen
+}
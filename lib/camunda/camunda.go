@@ -32,6 +32,10 @@ import (
 	"net/http"
 	"net/url"
 	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -40,29 +44,43 @@ type FactoryType struct{}
 var Factory = &FactoryType{}
 
 type Camunda struct {
-	config configuration.Config
-	shards *shards.Shards
+	config     configuration.Config
+	shards     *shards.Shards
+	resilience ResilienceConfig
+	clientsMux sync.Mutex
+	clients    map[string]*shardClient
+	metrics    interfaces.CamundaMetrics
 }
 
 func (this *FactoryType) Get(ctx context.Context, config configuration.Config) (interfaces.Camunda, error) {
+	resilience, err := NewResilienceConfig(config)
+	if err != nil {
+		return nil, err
+	}
 	s, err := shards.New(config.ShardsDb, cache.New(&cache.CacheConfig{L1Expiration: 60}))
 	if err != nil {
 		return nil, err
 	}
-	return &Camunda{config: config, shards: s}, nil
+	return &Camunda{config: config, shards: s, resilience: resilience, clients: map[string]*shardClient{}}, nil
+}
+
+// Close closes the connection to the shards database
+func (this *Camunda) Close() error {
+	log.Println("close shards db")
+	return this.shards.Close()
+}
+
+// NewLeaderLock returns a postgres advisory lock in the shards database
+func (this *Camunda) NewLeaderLock(name string) interfaces.LeaderLock {
+	return this.shards.NewAdvisoryLock(name)
 }
 
-func (this *Camunda) StopProcessInstance(id string, tenantId string) (err error) {
+func (this *Camunda) StopProcessInstance(ctx context.Context, id string, tenantId string) (err error) {
 	shard, err := this.shards.GetShardForUser(tenantId)
 	if err != nil {
 		return err
 	}
-	client := &http.Client{Timeout: 5 * time.Second}
-	request, err := http.NewRequest("DELETE", shard+"/engine-rest/process-instance/"+url.PathEscape(id)+"?skipIoMappings=true", nil)
-	if err != nil {
-		return err
-	}
-	resp, err := client.Do(request)
+	resp, err := this.do(ctx, shard, http.MethodDelete, "/engine-rest/process-instance/"+url.PathEscape(id)+"?skipIoMappings=true", nil)
 	if err != nil {
 		return err
 	}
@@ -78,21 +96,218 @@ func (this *Camunda) StopProcessInstance(id string, tenantId string) (err error)
 	return err
 }
 
+type ActivityIdWrapper struct {
+	ActivityId string `json:"activityId"`
+}
+
+// ResumeProcessInstance uses the process-instance modification api to cancel the failed activities of the instance
+// and to start the activity with targetActivityId; if targetActivityId is empty, the failed activity is started again
+func (this *Camunda) ResumeProcessInstance(ctx context.Context, id string, tenantId string, externalTaskId string, targetActivityId string) (err error) {
+	shard, err := this.shards.GetShardForUser(tenantId)
+	if err != nil {
+		return err
+	}
+	return this.resumeShardProcessInstance(ctx, shard, id, externalTaskId, targetActivityId)
+}
+
+// resumeShardProcessInstance is ResumeProcessInstance on a known shard
+func (this *Camunda) resumeShardProcessInstance(ctx context.Context, shard string, id string, externalTaskId string, targetActivityId string) (err error) {
+	failedActivities, err := this.getFailedActivityIds(ctx, shard, id, externalTaskId)
+	if err != nil {
+		return err
+	}
+	if len(failedActivities) == 0 {
+		return errors.New("unable to find failed activity of process-instance " + id)
+	}
+	if targetActivityId == "" {
+		targetActivityId = failedActivities[0]
+	}
+	instructions := []map[string]interface{}{}
+	for _, activityId := range failedActivities {
+		instructions = append(instructions, map[string]interface{}{"type": "cancel", "activityId": activityId})
+	}
+	instructions = append(instructions, map[string]interface{}{"type": "startBeforeActivity", "activityId": targetActivityId})
+
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(map[string]interface{}{
+		"skipCustomListeners": false,
+		"skipIoMappings":      false,
+		"instructions":        instructions,
+		"annotation":          "resumed by process-incident-worker",
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := this.do(ctx, shard, http.MethodPost, "/engine-rest/process-instance/"+url.PathEscape(id)+"/modification", b.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		temp, _ := io.ReadAll(resp.Body)
+		return errors.New("unable to modify process-instance " + id + ": " + resp.Status + " " + string(temp))
+	}
+	return nil
+}
+
+// getFailedActivityIds returns the activity of the external task (if still known to the engine)
+// followed by the activities of all incidents of the process instance
+func (this *Camunda) getFailedActivityIds(ctx context.Context, shard string, processInstanceId string, externalTaskId string) (result []string, err error) {
+	known := map[string]bool{}
+	if externalTaskId != "" {
+		resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/external-task/"+url.PathEscape(externalTaskId), nil)
+		if err != nil {
+			return result, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			task := ActivityIdWrapper{}
+			err = json.NewDecoder(resp.Body).Decode(&task)
+			if err != nil {
+				return result, err
+			}
+			if task.ActivityId != "" {
+				known[task.ActivityId] = true
+				result = append(result, task.ActivityId)
+			}
+		}
+	}
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/incident?processInstanceId="+url.QueryEscape(processInstanceId), nil)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		temp, _ := io.ReadAll(resp.Body)
+		return result, errors.New("unable to load incidents of process-instance: " + resp.Status + " " + string(temp))
+	}
+	incidents := []ActivityIdWrapper{}
+	err = json.NewDecoder(resp.Body).Decode(&incidents)
+	if err != nil {
+		return result, err
+	}
+	for _, incident := range incidents {
+		if incident.ActivityId != "" && !known[incident.ActivityId] {
+			known[incident.ActivityId] = true
+			result = append(result, incident.ActivityId)
+		}
+	}
+	return result, nil
+}
+
+// SetProcessDefinitionSuspended suspends or activates the process-definition; running instances are not affected
+func (this *Camunda) SetProcessDefinitionSuspended(ctx context.Context, id string, tenantId string, suspended bool) (err error) {
+	shard, err := this.shards.GetShardForUser(tenantId)
+	if err != nil {
+		return err
+	}
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(map[string]interface{}{
+		"suspended":               suspended,
+		"includeProcessInstances": false,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := this.do(ctx, shard, http.MethodPut, "/engine-rest/process-definition/"+url.PathEscape(id)+"/suspended", b.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		temp, _ := io.ReadAll(resp.Body)
+		return errors.New("unable to update suspension state of process-definition " + id + ": " + resp.Status + " " + string(temp))
+	}
+	return nil
+}
+
 type NameWrapper struct {
 	Name string `json:"name"`
 }
 
-func (this *Camunda) GetProcessName(id string, tenantId string) (name string, err error) {
+type TenantIdWrapper struct {
+	TenantId string `json:"tenantId"`
+}
+
+// IsProcessDefinitionOwner checks the camunda tenant id of the process-definition in the shard of the tenant
+func (this *Camunda) IsProcessDefinitionOwner(ctx context.Context, id string, tenantId string) (bool, error) {
 	shard, err := this.shards.GetShardForUser(tenantId)
+	if errors.Is(err, shards.ErrorNotFound) {
+		//nothing deployed by the tenant
+		return false, nil
+	}
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	return this.isShardProcessDefinitionOwner(ctx, shard, id, tenantId)
+}
+
+func (this *Camunda) isShardProcessDefinitionOwner(ctx context.Context, shard string, id string, tenantId string) (bool, error) {
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/process-definition/"+url.PathEscape(id), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
 	}
-	client := &http.Client{Timeout: 5 * time.Second}
-	request, err := http.NewRequest("GET", shard+"/engine-rest/process-definition/"+url.PathEscape(id), nil)
+	if resp.StatusCode != 200 {
+		temp, _ := io.ReadAll(resp.Body)
+		log.Println("ERROR:", resp.Status, string(temp))
+		return false, errors.New("unexpected response")
+	}
+	result := TenantIdWrapper{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return false, err
+	}
+	return result.TenantId == tenantId, nil
+}
+
+// ListProcessDefinitionIds returns the process-definitions with the camunda tenant id of the tenant in its shard
+func (this *Camunda) ListProcessDefinitionIds(ctx context.Context, tenantId string) ([]string, error) {
+	shard, err := this.shards.GetShardForUser(tenantId)
+	if errors.Is(err, shards.ErrorNotFound) {
+		//nothing deployed by the tenant
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return this.listShardProcessDefinitionIds(ctx, shard, tenantId)
+}
+
+func (this *Camunda) listShardProcessDefinitionIds(ctx context.Context, shard string, tenantId string) ([]string, error) {
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/process-definition?tenantIdIn="+url.QueryEscape(tenantId), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		temp, _ := io.ReadAll(resp.Body)
+		log.Println("ERROR:", resp.Status, string(temp))
+		return nil, errors.New("unexpected response")
+	}
+	definitions := []struct {
+		Id string `json:"id"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&definitions)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, definition := range definitions {
+		result = append(result, definition.Id)
+	}
+	return result, nil
+}
+
+func (this *Camunda) GetProcessName(ctx context.Context, id string, tenantId string) (name string, err error) {
+	shard, err := this.shards.GetShardForUser(tenantId)
 	if err != nil {
 		return "", err
 	}
-	resp, err := client.Do(request)
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/process-definition/"+url.PathEscape(id), nil)
 	if err != nil {
 		return "", err
 	}
@@ -108,35 +323,50 @@ func (this *Camunda) GetProcessName(id string, tenantId string) (name string, er
 	return result.Name, err
 }
 
-func (this *Camunda) StartProcess(processDefinitionId string, userId string) (err error) {
+// StartProcess starts the process definition with the given variables as start-parameters.
+// variables not known as start-parameter of the definition are ignored; start-parameters without variable use the default value of the form,
+// missing start-parameters without default result in an error
+func (this *Camunda) StartProcess(ctx context.Context, processDefinitionId string, userId string, variables map[string]interface{}) (processInstanceId string, err error) {
 	shard, err := this.shards.EnsureShardForUser(userId)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return this.startShardProcess(ctx, shard, processDefinitionId, variables)
+}
 
-	parameters, err := this.getProcessParameters(shard, processDefinitionId)
+func (this *Camunda) startShardProcess(ctx context.Context, shard string, processDefinitionId string, variables map[string]interface{}) (processInstanceId string, err error) {
+	parameters, err := this.getProcessParameters(ctx, shard, processDefinitionId)
 	if err != nil {
-		return err
+		return "", err
+	}
+	startParameters := map[string]interface{}{}
+	missing := []string{}
+	for key, parameter := range parameters {
+		value, ok := variables[key]
+		if !ok && parameter.Value != nil {
+			value, ok = parameter.Value, true
+		}
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		startParameters[key] = value
 	}
-	if len(parameters) > 0 {
-		return errors.New("restart of processes with start-parameters not supported")
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", errors.New("restart of process is missing values for start-parameters: " + strings.Join(missing, ", "))
 	}
 
-	//message := createStartMessage(nil)
+	message := createStartMessage(startParameters)
 
 	b := new(bytes.Buffer)
-	err = json.NewEncoder(b).Encode(map[string]interface{}{})
+	err = json.NewEncoder(b).Encode(message)
 	if err != nil {
 		return
 	}
-	req, err := http.NewRequest("POST", shard+"/engine-rest/process-definition/"+url.QueryEscape(processDefinitionId)+"/submit-form", b)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := this.do(ctx, shard, http.MethodPost, "/engine-rest/process-definition/"+url.QueryEscape(processDefinitionId)+"/submit-form", b.Bytes())
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	defer resp.Body.Close()
@@ -144,9 +374,18 @@ func (this *Camunda) StartProcess(processDefinitionId string, userId string) (er
 
 	if resp.StatusCode != http.StatusOK {
 		err = errors.New(resp.Status + " " + string(temp))
-		return err
+		return "", err
 	}
-	return nil
+	instance := ProcessInstance{}
+	err = json.Unmarshal(temp, &instance)
+	if err != nil {
+		return "", err
+	}
+	return instance.Id, nil
+}
+
+type ProcessInstance struct {
+	Id string `json:"id"`
 }
 
 type Variable struct {
@@ -155,12 +394,8 @@ type Variable struct {
 	ValueInfo interface{} `json:"valueInfo"`
 }
 
-func (this *Camunda) getProcessParameters(shard string, processDefinitionId string) (result map[string]Variable, err error) {
-	req, err := http.NewRequest("GET", shard+"/engine-rest/process-definition/"+url.QueryEscape(processDefinitionId)+"/form-variables", nil)
-	if err != nil {
-		return result, err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (this *Camunda) getProcessParameters(ctx context.Context, shard string, processDefinitionId string) (result map[string]Variable, err error) {
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/process-definition/"+url.QueryEscape(processDefinitionId)+"/form-variables", nil)
 	if err != nil {
 		return result, err
 	}
@@ -174,6 +409,40 @@ func (this *Camunda) getProcessParameters(shard string, processDefinitionId stri
 	return
 }
 
+// GetProcessInstanceVariables returns the values of the variables of the instance; the result of unknown instances is empty
+func (this *Camunda) GetProcessInstanceVariables(ctx context.Context, id string, tenantId string) (result map[string]interface{}, err error) {
+	shard, err := this.shards.GetShardForUser(tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return this.getShardProcessInstanceVariables(ctx, shard, id)
+}
+
+func (this *Camunda) getShardProcessInstanceVariables(ctx context.Context, shard string, id string) (result map[string]interface{}, err error) {
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/process-instance/"+url.PathEscape(id)+"/variables", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return map[string]interface{}{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		temp, _ := io.ReadAll(resp.Body)
+		return nil, errors.New(resp.Status + " " + string(temp))
+	}
+	variables := map[string]Variable{}
+	err = json.NewDecoder(resp.Body).Decode(&variables)
+	if err != nil {
+		return nil, err
+	}
+	result = map[string]interface{}{}
+	for key, variable := range variables {
+		result[key] = variable.Value
+	}
+	return result, nil
+}
+
 func createStartMessage(parameter map[string]interface{}) map[string]interface{} {
 	if len(parameter) == 0 {
 		return map[string]interface{}{}
@@ -187,24 +456,93 @@ func createStartMessage(parameter map[string]interface{}) map[string]interface{}
 	return map[string]interface{}{"variables": variables}
 }
 
-func (this *Camunda) GetIncidents() (result []messages.CamundaIncident, err error) {
+// GetIncidents polls all shards in parallel; a failing shard is reported but does not hide the incidents of the other shards
+func (this *Camunda) GetIncidents(ctx context.Context, query interfaces.CamundaIncidentQuery) (result []messages.CamundaIncident, report map[string]interfaces.CamundaShardReport, err error) {
 	shards, err := this.shards.GetShards()
 	if err != nil {
-		return result, err
+		return result, nil, err
+	}
+	incidents := make([][]messages.CamundaIncident, len(shards))
+	reports := make([]interfaces.CamundaShardReport, len(shards))
+	wg := sync.WaitGroup{}
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard string) {
+			defer wg.Done()
+			start := time.Now()
+			incidents[i], reports[i].Err = this.GetShardIncidents(ctx, shard, query)
+			reports[i].Incidents = len(incidents[i])
+			reports[i].Duration = time.Since(start)
+		}(i, shard)
+	}
+	wg.Wait()
+	report = map[string]interfaces.CamundaShardReport{}
+	for i, shard := range shards {
+		report[shard] = reports[i]
+		if reports[i].Err == nil {
+			result = append(result, incidents[i]...)
+		}
+	}
+	return result, report, nil
+}
+
+// GetShardIncidents loads the incidents of the shard page by page, sorted by their timestamp.
+// every page starts at the timestamp of the last loaded incident instead of an offset, so incidents deleted while paging do not shift
+// the following incidents out of the result; incidents of the same timestamp are loaded again and skipped by their id.
+// only if a full page shares one timestamp, the next page of that timestamp is loaded by offset
+func (this *Camunda) GetShardIncidents(ctx context.Context, shard string, query interfaces.CamundaIncidentQuery) (result []messages.CamundaIncident, err error) {
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = interfaces.DefaultCamundaIncidentPageSize
+	}
+	values := url.Values{}
+	values.Set("sortBy", "incidentTimestamp")
+	values.Set("sortOrder", "asc")
+	values.Set("maxResults", strconv.Itoa(pageSize))
+	if query.IncidentType != "" {
+		values.Set("incidentType", query.IncidentType)
+	}
+	if !query.After.IsZero() {
+		values.Set("incidentTimestampAfter", query.After.Format(CamundaTimeFormat))
 	}
-	for _, shard := range shards {
-		temp, err := this.GetShardIncidents(shard)
+	seen := map[string]bool{}
+	offset := 0
+	for {
+		values.Set("firstResult", strconv.Itoa(offset))
+		page, err := this.getIncidentPage(ctx, shard, values)
 		if err != nil {
-			return result, err
+			return nil, err
+		}
+		added := 0
+		for _, incident := range page {
+			if !seen[incident.Id] {
+				seen[incident.Id] = true
+				result = append(result, incident)
+				added++
+			}
+		}
+		if len(page) < pageSize {
+			return result, nil
 		}
-		result = append(result, temp...)
+		last, err := time.Parse(CamundaTimeFormat, page[len(page)-1].IncidentTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		after := last.Add(-time.Millisecond).Format(CamundaTimeFormat)
+		if after != values.Get("incidentTimestampAfter") {
+			offset = 0
+		} else if added == 0 {
+			offset = offset + pageSize
+		}
+		values.Set("incidentTimestampAfter", after)
 	}
-	return result, nil
 }
 
-func (this *Camunda) GetShardIncidents(shard string) (result []messages.CamundaIncident, err error) {
-	client := http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(shard + "/engine-rest/incident")
+// CamundaTimeFormat is the date format of camunda rest query parameters
+const CamundaTimeFormat = "2006-01-02T15:04:05.000-0700"
+
+func (this *Camunda) getIncidentPage(ctx context.Context, shard string, values url.Values) (result []messages.CamundaIncident, err error) {
+	resp, err := this.do(ctx, shard, http.MethodGet, "/engine-rest/incident?"+values.Encode(), nil)
 	if err != nil {
 		return result, err
 	}
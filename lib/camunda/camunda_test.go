@@ -0,0 +1,257 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetShardIncidents(t *testing.T) {
+	after := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	//incidents 2-4 share a timestamp and do not fit into one page
+	stored := []messages.CamundaIncident{}
+	for i := 0; i < 5; i++ {
+		timestamp := after.Add(time.Second)
+		if i >= 2 {
+			timestamp = after.Add(2 * time.Second)
+		}
+		stored = append(stored, messages.CamundaIncident{Id: strconv.Itoa(i), IncidentTimestamp: timestamp.Format(CamundaTimeFormat)})
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+		query := request.URL.Query()
+		if request.URL.Path != "/engine-rest/incident" || query.Get("incidentType") != "failedExternalTask" || query.Get("sortBy") != "incidentTimestamp" {
+			t.Error(request.URL.String())
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if requests == 1 && query.Get("incidentTimestampAfter") != "2025-01-02T03:04:05.000+0000" {
+			t.Error(request.URL.String())
+		}
+		queryAfter, err := time.Parse(CamundaTimeFormat, query.Get("incidentTimestampAfter"))
+		if err != nil {
+			t.Error(err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		matching := []messages.CamundaIncident{}
+		for _, incident := range stored {
+			timestamp, _ := time.Parse(CamundaTimeFormat, incident.IncidentTimestamp)
+			if timestamp.After(queryAfter) {
+				matching = append(matching, incident)
+			}
+		}
+		first, _ := strconv.Atoi(query.Get("firstResult"))
+		max, _ := strconv.Atoi(query.Get("maxResults"))
+		page := matching[min(first, len(matching)):min(first+max, len(matching))]
+		_ = json.NewEncoder(writer).Encode(page)
+		if requests == 1 {
+			stored = stored[1:] //deleted while paging
+		}
+	}))
+	defer server.Close()
+
+	c := &Camunda{resilience: ResilienceConfig{Timeout: time.Second}, clients: map[string]*shardClient{}}
+	result, err := c.GetShardIncidents(context.Background(), server.URL, interfaces.CamundaIncidentQuery{IncidentType: "failedExternalTask", After: after, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, incident := range result {
+		ids = append(ids, incident.Id)
+	}
+	if !reflect.DeepEqual(ids, []string{"0", "1", "2", "3", "4"}) {
+		t.Fatal(ids, requests)
+	}
+}
+
+func TestResumeShardProcessInstance(t *testing.T) {
+	type instruction struct {
+		Type       string `json:"type"`
+		ActivityId string `json:"activityId"`
+	}
+	var modification struct {
+		Instructions []instruction `json:"instructions"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case request.Method == http.MethodGet && request.URL.Path == "/engine-rest/external-task/task1":
+			_ = json.NewEncoder(writer).Encode(ActivityIdWrapper{ActivityId: "a1"})
+		case request.Method == http.MethodGet && request.URL.Path == "/engine-rest/external-task/unknown":
+			writer.WriteHeader(http.StatusNotFound)
+		case request.Method == http.MethodGet && request.URL.Path == "/engine-rest/incident" && request.URL.Query().Get("processInstanceId") == "i1":
+			_ = json.NewEncoder(writer).Encode([]ActivityIdWrapper{{ActivityId: "a1"}, {ActivityId: "a2"}})
+		case request.Method == http.MethodGet && request.URL.Path == "/engine-rest/incident":
+			_ = json.NewEncoder(writer).Encode([]ActivityIdWrapper{})
+		case request.Method == http.MethodPost && request.URL.Path == "/engine-rest/process-instance/i1/modification":
+			modification.Instructions = nil
+			err := json.NewDecoder(request.Body).Decode(&modification)
+			if err != nil {
+				t.Error(err)
+			}
+			writer.WriteHeader(http.StatusNoContent)
+		default:
+			t.Error(request.Method, request.URL.String())
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	c := &Camunda{resilience: ResilienceConfig{Timeout: time.Second}, clients: map[string]*shardClient{}}
+
+	err := c.resumeShardProcessInstance(context.Background(), server.URL, "i1", "task1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []instruction{{Type: "cancel", ActivityId: "a1"}, {Type: "cancel", ActivityId: "a2"}, {Type: "startBeforeActivity", ActivityId: "a1"}}
+	if !reflect.DeepEqual(modification.Instructions, expected) {
+		t.Fatalf("%#v", modification.Instructions)
+	}
+
+	//the external task of finished attempts is unknown to the engine -> activities of the incidents
+	err = c.resumeShardProcessInstance(context.Background(), server.URL, "i1", "unknown", "target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []instruction{{Type: "cancel", ActivityId: "a1"}, {Type: "cancel", ActivityId: "a2"}, {Type: "startBeforeActivity", ActivityId: "target"}}
+	if !reflect.DeepEqual(modification.Instructions, expected) {
+		t.Fatalf("%#v", modification.Instructions)
+	}
+
+	//without failed activity the controller falls back to stop and restart
+	err = c.resumeShardProcessInstance(context.Background(), server.URL, "i2", "unknown", "")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestIsShardProcessDefinitionOwner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/engine-rest/process-definition/d1":
+			_ = json.NewEncoder(writer).Encode(TenantIdWrapper{TenantId: "user1"})
+		case "/engine-rest/process-definition":
+			if request.URL.Query().Get("tenantIdIn") != "user1" {
+				_, _ = writer.Write([]byte("[]"))
+				return
+			}
+			_, _ = writer.Write([]byte(`[{"id": "d1", "tenantId": "user1"}, {"id": "d2", "tenantId": "user1"}]`))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := &Camunda{resilience: ResilienceConfig{Timeout: time.Second}, clients: map[string]*shardClient{}}
+
+	for _, test := range []struct {
+		id       string
+		tenantId string
+		expected bool
+	}{
+		{id: "d1", tenantId: "user1", expected: true},
+		{id: "d1", tenantId: "user2", expected: false},
+		{id: "unknown", tenantId: "user1", expected: false},
+	} {
+		owner, err := c.isShardProcessDefinitionOwner(context.Background(), server.URL, test.id, test.tenantId)
+		if err != nil || owner != test.expected {
+			t.Error(test.id, test.tenantId, owner, err)
+		}
+	}
+
+	ids, err := c.listShardProcessDefinitionIds(context.Background(), server.URL, "user1")
+	if err != nil || !reflect.DeepEqual(ids, []string{"d1", "d2"}) {
+		t.Error(ids, err)
+	}
+	ids, err = c.listShardProcessDefinitionIds(context.Background(), server.URL, "user2")
+	if err != nil || len(ids) != 0 {
+		t.Error(ids, err)
+	}
+}
+
+func TestStartShardProcess(t *testing.T) {
+	var submitted map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case request.Method == http.MethodGet && request.URL.Path == "/engine-rest/process-definition/d1/form-variables":
+			_, _ = writer.Write([]byte(`{"a": {"value": null, "type": "Long"}, "b": {"value": "default", "type": "String"}}`))
+		case request.Method == http.MethodPost && request.URL.Path == "/engine-rest/process-definition/d1/submit-form":
+			submitted = nil
+			err := json.NewDecoder(request.Body).Decode(&submitted)
+			if err != nil {
+				t.Error(err)
+			}
+			_ = json.NewEncoder(writer).Encode(ProcessInstance{Id: "new-instance"})
+		default:
+			t.Error(request.Method, request.URL.String())
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	c := &Camunda{resilience: ResilienceConfig{Timeout: time.Second}, clients: map[string]*shardClient{}}
+
+	//unknown variables are ignored, b uses the default of the form
+	id, err := c.startShardProcess(context.Background(), server.URL, "d1", map[string]interface{}{"a": 42, "c": "unknown"})
+	if err != nil || id != "new-instance" {
+		t.Fatal(id, err)
+	}
+	expected := map[string]interface{}{"variables": map[string]interface{}{
+		"a": map[string]interface{}{"value": float64(42)},
+		"b": map[string]interface{}{"value": "default"},
+	}}
+	if !reflect.DeepEqual(submitted, expected) {
+		t.Fatalf("%#v", submitted)
+	}
+
+	submitted = nil
+	_, err = c.startShardProcess(context.Background(), server.URL, "d1", map[string]interface{}{"b": "value"})
+	if err == nil || !strings.Contains(err.Error(), "start-parameters: a") || submitted != nil {
+		t.Fatal(err, submitted)
+	}
+}
+
+func TestGetShardProcessInstanceVariables(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/engine-rest/process-instance/i1/variables":
+			_, _ = writer.Write([]byte(`{"a": {"value": 42, "type": "Long"}, "b": {"value": "text", "type": "String"}}`))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := &Camunda{resilience: ResilienceConfig{Timeout: time.Second}, clients: map[string]*shardClient{}}
+
+	variables, err := c.getShardProcessInstanceVariables(context.Background(), server.URL, "i1")
+	if err != nil || !reflect.DeepEqual(variables, map[string]interface{}{"a": float64(42), "b": "text"}) {
+		t.Fatal(variables, err)
+	}
+	//finished instances are unknown to the engine
+	variables, err = c.getShardProcessInstanceVariables(context.Background(), server.URL, "finished")
+	if err != nil || variables == nil || len(variables) != 0 {
+		t.Fatal(variables, err)
+	}
+}
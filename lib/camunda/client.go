@@ -0,0 +1,192 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ResilienceConfig struct {
+	Timeout             time.Duration //per attempt
+	Retries             int           //additional attempts of idempotent requests
+	RetryWait           time.Duration //base of the exponential wait between attempts; the actual wait is randomized (full jitter)
+	RetryMaxWait        time.Duration
+	BreakerThreshold    int //consecutive failures that open the breaker of a shard; 0 disables the breaker
+	BreakerOpenDuration time.Duration
+}
+
+var DefaultResilienceConfig = ResilienceConfig{
+	Timeout:             10 * time.Second,
+	Retries:             2,
+	RetryWait:           500 * time.Millisecond,
+	RetryMaxWait:        5 * time.Second,
+	BreakerThreshold:    5,
+	BreakerOpenDuration: 30 * time.Second,
+}
+
+// NewResilienceConfig uses DefaultResilienceConfig for empty durations
+func NewResilienceConfig(config configuration.Config) (result ResilienceConfig, err error) {
+	result = DefaultResilienceConfig
+	for _, field := range []struct {
+		value  string
+		target *time.Duration
+	}{
+		{value: config.CamundaTimeout, target: &result.Timeout},
+		{value: config.CamundaRetryWait, target: &result.RetryWait},
+		{value: config.CamundaRetryMaxWait, target: &result.RetryMaxWait},
+		{value: config.CamundaBreakerOpenDuration, target: &result.BreakerOpenDuration},
+	} {
+		if field.value != "" {
+			*field.target, err = time.ParseDuration(field.value)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+	result.Retries = int(config.CamundaRetries)
+	result.BreakerThreshold = int(config.CamundaBreakerThreshold)
+	return result, nil
+}
+
+type shardClient struct {
+	http    *http.Client
+	breaker *CircuitBreaker
+}
+
+func (this *Camunda) getShardClient(shard string) *shardClient {
+	this.clientsMux.Lock()
+	defer this.clientsMux.Unlock()
+	client, ok := this.clients[shard]
+	if !ok {
+		client = &shardClient{
+			http:    &http.Client{Timeout: this.resilience.Timeout},
+			breaker: NewCircuitBreaker(this.resilience.BreakerThreshold, this.resilience.BreakerOpenDuration),
+		}
+		this.clients[shard] = client
+	}
+	return client
+}
+
+// GetShardStates returns the BreakerState... of every shard that has been called
+func (this *Camunda) GetShardStates() map[string]string {
+	this.clientsMux.Lock()
+	defer this.clientsMux.Unlock()
+	result := map[string]string{}
+	for shard, client := range this.clients {
+		result[shard] = client.breaker.State()
+	}
+	return result
+}
+
+// do sends the request to shard+path; idempotent requests (GET, PUT, DELETE) are retried on network errors and 5xx responses.
+// while the circuit breaker of the shard is open, an interfaces.ShardUnavailableError is returned without request.
+// every attempt is traced as child span of ctx; the wait between retries ends early with ctx.Err() when ctx is done
+func (this *Camunda) do(ctx context.Context, shard string, method string, path string, body []byte) (resp *http.Response, err error) {
+	client := this.getShardClient(shard)
+	idempotent := method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+	for attempt := 0; ; attempt++ {
+		ok, retryAt := client.breaker.Allow()
+		if !ok {
+			this.notifyRequest(shard, method, "unavailable", 0)
+			return nil, interfaces.ShardUnavailableError{Shard: shard, RetryAt: retryAt}
+		}
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		spanCtx, span := tracing.StartSpan(ctx, "camunda "+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("server.address", shard),
+			attribute.String("url.path", strings.SplitN(path, "?", 2)[0]),
+			attribute.Int("http.request.resend_count", attempt),
+		))
+		req, err := http.NewRequestWithContext(spanCtx, method, shard+path, reader)
+		if err != nil {
+			client.breaker.Done(true)
+			tracing.EndSpan(span, err)
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		tracing.InjectHeaders(spanCtx, propagation.HeaderCarrier(req.Header))
+		start := time.Now()
+		resp, err = client.http.Do(req)
+		if err != nil {
+			this.notifyRequest(shard, method, "error", time.Since(start))
+			tracing.EndSpan(span, err)
+		} else {
+			this.notifyRequest(shard, method, strconv.Itoa(resp.StatusCode), time.Since(start))
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			if resp.StatusCode >= 500 {
+				tracing.EndSpan(span, errors.New(resp.Status))
+			} else {
+				tracing.EndSpan(span, nil)
+			}
+		}
+		failed := err != nil || resp.StatusCode >= 500
+		client.breaker.Done(!failed)
+		if !failed || !idempotent || attempt >= this.resilience.Retries {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(this.getRetryWait(attempt)):
+		}
+	}
+}
+
+// SetCamundaMetrics sets the receiver of the per request metrics; without metrics no requests are reported
+func (this *Camunda) SetCamundaMetrics(metrics interfaces.CamundaMetrics) {
+	this.metrics = metrics
+}
+
+func (this *Camunda) notifyRequest(shard string, method string, status string, duration time.Duration) {
+	if this.metrics != nil {
+		this.metrics.NotifyCamundaRequest(shard, method, status, duration)
+	}
+}
+
+func (this *Camunda) getRetryWait(attempt int) time.Duration {
+	wait := this.resilience.RetryWait << attempt
+	if wait > this.resilience.RetryMaxWait || wait <= 0 {
+		wait = this.resilience.RetryMaxWait
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return rand.N(wait)
+}
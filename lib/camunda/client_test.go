@@ -0,0 +1,179 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(2, 100*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if ok, _ := breaker.Allow(); !ok {
+			t.Fatal("closed breaker rejected call", i)
+		}
+		breaker.Done(false)
+	}
+	if ok, retryAt := breaker.Allow(); ok || retryAt.IsZero() || breaker.State() != BreakerStateOpen {
+		t.Fatal(ok, retryAt, breaker.State())
+	}
+	time.Sleep(150 * time.Millisecond)
+	if ok, _ := breaker.Allow(); !ok {
+		t.Fatal("half-open breaker rejected probe")
+	}
+	if ok, _ := breaker.Allow(); ok {
+		t.Fatal("half-open breaker allowed second call during probe")
+	}
+	breaker.Done(false)
+	if breaker.State() != BreakerStateOpen {
+		t.Fatal(breaker.State())
+	}
+	time.Sleep(150 * time.Millisecond)
+	if ok, _ := breaker.Allow(); !ok {
+		t.Fatal("half-open breaker rejected probe")
+	}
+	breaker.Done(true)
+	if breaker.State() != BreakerStateClosed {
+		t.Fatal(breaker.State())
+	}
+
+	disabled := NewCircuitBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		disabled.Done(false)
+	}
+	if ok, _ := disabled.Allow(); !ok {
+		t.Fatal("disabled breaker rejected call")
+	}
+}
+
+type testRequestMetrics struct {
+	requests map[string]int
+}
+
+func (this *testRequestMetrics) NotifyCamundaRequest(shard string, method string, status string, duration time.Duration) {
+	this.requests[method+" "+status]++
+}
+
+func TestResilientRequests(t *testing.T) {
+	calls := atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls.Add(1)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := &Camunda{
+		resilience: ResilienceConfig{
+			Timeout:             time.Second,
+			Retries:             2,
+			RetryWait:           time.Millisecond,
+			RetryMaxWait:        10 * time.Millisecond,
+			BreakerThreshold:    5,
+			BreakerOpenDuration: time.Minute,
+		},
+		clients: map[string]*shardClient{},
+	}
+	metrics := &testRequestMetrics{requests: map[string]int{}}
+	c.SetCamundaMetrics(metrics)
+
+	//idempotent requests are retried
+	resp, err := c.do(context.Background(), server.URL, http.MethodGet, "/engine-rest/incident", nil)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 3 {
+		t.Fatal(err, calls.Load())
+	}
+	resp.Body.Close()
+
+	//post requests are not retried
+	resp, err = c.do(context.Background(), server.URL, http.MethodPost, "/engine-rest/process-definition/d1/submit-form", []byte("{}"))
+	if err != nil || calls.Load() != 4 {
+		t.Fatal(err, calls.Load())
+	}
+	resp.Body.Close()
+
+	//fifth failure opens the breaker
+	_, err = c.do(context.Background(), server.URL, http.MethodGet, "/engine-rest/incident", nil)
+	if !errors.Is(err, interfaces.ErrShardUnavailable) || calls.Load() != 5 {
+		t.Fatal(err, calls.Load())
+	}
+	unavailable := interfaces.ShardUnavailableError{}
+	if !errors.As(err, &unavailable) || unavailable.Shard != server.URL || time.Until(unavailable.RetryAt) < 50*time.Second {
+		t.Fatalf("%#v", unavailable)
+	}
+	if c.GetShardStates()[server.URL] != BreakerStateOpen {
+		t.Fatal(c.GetShardStates())
+	}
+	if metrics.requests["GET 503"] != 4 || metrics.requests["POST 503"] != 1 || metrics.requests["GET unavailable"] != 1 {
+		t.Fatal(metrics.requests)
+	}
+}
+
+func TestRetryWaitCanceled(t *testing.T) {
+	calls := atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls.Add(1)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := &Camunda{
+		resilience: ResilienceConfig{
+			Timeout:             time.Second,
+			Retries:             2,
+			RetryWait:           time.Minute,
+			RetryMaxWait:        time.Minute,
+			BreakerThreshold:    5,
+			BreakerOpenDuration: time.Minute,
+		},
+		clients: map[string]*shardClient{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.do(ctx, server.URL, http.MethodGet, "/engine-rest/incident", nil)
+	if !errors.Is(err, context.DeadlineExceeded) || calls.Load() != 1 || time.Since(start) > 10*time.Second {
+		t.Fatal(err, calls.Load(), time.Since(start))
+	}
+}
+
+func TestCheckShard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/engine-rest/engine" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = writer.Write([]byte(`[{"name":"default"}]`))
+	}))
+	defer server.Close()
+
+	c := &Camunda{resilience: ResilienceConfig{Timeout: time.Second, BreakerThreshold: 1, BreakerOpenDuration: time.Minute}, clients: map[string]*shardClient{}}
+	err := c.checkShard(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.getShardClient(server.URL).breaker.Done(false)
+	err = c.checkShard(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("expected error for open breaker")
+	}
+}
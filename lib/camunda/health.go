@@ -0,0 +1,79 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// RegisterHealthChecks registers the shards database and the reachability of all camunda shards
+func (this *Camunda) RegisterHealthChecks(registry interfaces.HealthRegistry) {
+	registry.Register("shards-db", this.shards.Ping)
+	registry.Register("camunda-shards", this.CheckShards)
+}
+
+// CheckShards requests the engine list of every shard; the requests are not counted by the circuit breakers,
+// but shards with an open breaker are reported as unavailable
+func (this *Camunda) CheckShards(ctx context.Context) error {
+	shards, err := this.shards.GetShards()
+	if err != nil {
+		return err
+	}
+	mux := sync.Mutex{}
+	errs := []error{}
+	wg := sync.WaitGroup{}
+	for _, shard := range shards {
+		wg.Add(1)
+		go func(shard string) {
+			defer wg.Done()
+			err := this.checkShard(ctx, shard)
+			if err != nil {
+				mux.Lock()
+				defer mux.Unlock()
+				errs = append(errs, errors.New(shard+": "+err.Error()))
+			}
+		}(shard)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (this *Camunda) checkShard(ctx context.Context, shard string) error {
+	client := this.getShardClient(shard)
+	if client.breaker.State() == BreakerStateOpen {
+		return errors.New("circuit breaker open")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shard+"/engine-rest/engine", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		temp, _ := io.ReadAll(resp.Body)
+		return errors.New(resp.Status + " " + string(temp))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package shards
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"sync"
+)
+
+// AdvisoryLock is a session level postgres advisory lock. the lock is held by a dedicated connection;
+// postgres releases it when the connection (e.g. of a crashed replica) is closed
+type AdvisoryLock struct {
+	db   *sql.DB
+	key  int64
+	mux  sync.Mutex
+	conn *sql.Conn
+}
+
+// NewAdvisoryLock creates a lock that is shared by all users of the shards database with the same name
+func (this *Shards) NewAdvisoryLock(name string) *AdvisoryLock {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name))
+	return &AdvisoryLock{db: this.db, key: int64(hash.Sum64())}
+}
+
+// TryLock acquires the lock or, if it is already held, checks that its connection is still alive
+func (this *AdvisoryLock) TryLock(ctx context.Context) (held bool, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.conn != nil {
+		_, err = this.conn.ExecContext(ctx, "SELECT 1")
+		if err != nil {
+			this.release()
+			return false, err
+		}
+		return true, nil
+	}
+	conn, err := this.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", this.key).Scan(&held)
+	if err != nil || !held {
+		_ = conn.Close()
+		return false, err
+	}
+	this.conn = conn
+	return true, nil
+}
+
+// Unlock releases the lock by closing its connection
+func (this *AdvisoryLock) Unlock() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.release()
+	return nil
+}
+
+// release discards the connection instead of returning it to the pool, where it would keep the lock
+func (this *AdvisoryLock) release() {
+	if this.conn == nil {
+		return
+	}
+	_ = this.conn.Raw(func(driverConn any) error {
+		return driver.ErrBadConn
+	})
+	_ = this.conn.Close()
+	this.conn = nil
+}
@@ -56,6 +56,14 @@ type Shards struct {
 
 var ErrorNotFound = errors.New("no shard assigned to user")
 
+func (this *Shards) Ping(ctx context.Context) error {
+	return this.db.PingContext(ctx)
+}
+
+func (this *Shards) Close() error {
+	return this.db.Close()
+}
+
 const CachePrefix = "user-shard."
 
 func (this *Shards) GetShardForUser(userId string) (shardUrl string, err error) {
@@ -18,15 +18,30 @@ package camundasource
 
 import (
 	"context"
+	"errors"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/controller"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/leader"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
 	"log"
+	"sync"
 	"time"
 )
 
-func Start(ctx context.Context, config configuration.Config, camunda interfaces.Camunda, ctrl *controller.Controller) error {
+// LeaderElection is the name of the advisory lock held by the polling replica
+const LeaderElection = "process-incident-worker:camunda-incident-poll"
+
+// Metrics receives the per shard results of every poll and the leadership of the replica
+type Metrics interface {
+	NotifyCamundaPoll(shard string, incidents int, duration time.Duration, err error)
+	leader.Metrics
+}
+
+// Start polls the incidents of camunda until ctx is done; running is Done after the in-flight incidents are handled.
+// with config.CamundaPollLeaderElection only the elected replica polls
+func Start(ctx context.Context, config configuration.Config, camunda interfaces.Camunda, ctrl *controller.Controller, metrics Metrics, running *sync.WaitGroup) error {
 	interval := time.Second
 	var err error
 	if config.CamundaIncidentRequestInterval != "" && config.CamundaIncidentRequestInterval != "-" {
@@ -37,37 +52,94 @@ func Start(ctx context.Context, config configuration.Config, camunda interfaces.
 	} else {
 		return nil
 	}
-	go func() {
+	var maxAge time.Duration
+	if config.CamundaIncidentMaxAge != "" && config.CamundaIncidentMaxAge != "-" {
+		maxAge, err = time.ParseDuration(config.CamundaIncidentMaxAge)
+		if err != nil {
+			return err
+		}
+	}
+	query := interfaces.CamundaIncidentQuery{IncidentType: config.CamundaIncidentType, PageSize: int(config.CamundaIncidentPageSize)}
+	loop := func(ctx context.Context) {
 		for {
+			if maxAge > 0 {
+				query.After = time.Now().Add(-maxAge)
+			}
+			poll(ctx, camunda, ctrl, metrics, query)
 			select {
 			case <-ctx.Done():
 				return
-			default:
-				incidents, err := camunda.GetIncidents()
-				if err != nil {
-					log.Println("WARNING: unable to load camunda incidents", err)
-					continue
-				}
-				for _, incident := range incidents {
-					err = ctrl.CreateIncident(messages.Incident{
-						Id:                  incident.Id,
-						MsgVersion:          3,
-						ExternalTaskId:      incident.ActivityId,
-						ProcessInstanceId:   incident.ProcessInstanceId,
-						ProcessDefinitionId: incident.ProcessDefinitionId,
-						WorkerId:            "process-incident-worker",
-						ErrorMessage:        incident.IncidentMessage,
-						Time:                time.Now(),
-						TenantId:            incident.TenantId,
-					})
-					if err != nil {
-						log.Println("WARNING: unable to handle camunda incidents", err)
-						continue
-					}
-				}
-				time.Sleep(interval)
+			case <-time.After(interval):
 			}
 		}
+	}
+	if !config.CamundaPollLeaderElection {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			loop(ctx)
+		}()
+		return nil
+	}
+	provider, ok := camunda.(interfaces.LeaderLockProvider)
+	if !ok {
+		return errors.New("camunda_poll_leader_election is not supported by the camunda implementation")
+	}
+	leaderInterval := leader.DefaultInterval
+	if config.CamundaPollLeaderInterval != "" && config.CamundaPollLeaderInterval != "-" {
+		leaderInterval, err = time.ParseDuration(config.CamundaPollLeaderInterval)
+		if err != nil {
+			return err
+		}
+	}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		leader.Run(ctx, LeaderElection, provider.NewLeaderLock(LeaderElection), leaderInterval, metrics, loop)
 	}()
 	return nil
 }
+
+// poll handles the incidents of all reachable shards; unreachable shards are logged and retried with the next poll
+func poll(ctx context.Context, camunda interfaces.Camunda, ctrl *controller.Controller, metrics Metrics, query interfaces.CamundaIncidentQuery) {
+	//the span is a child of ctx, but handling an incident is not canceled with the loop; the loop stops between incidents
+	pollCtx, span := tracing.StartSpan(context.WithoutCancel(ctx), "poll camunda incidents")
+	incidents, report, err := camunda.GetIncidents(pollCtx, query)
+	if err != nil {
+		log.Println("WARNING: unable to load camunda incidents", err)
+		tracing.EndSpan(span, err)
+		return
+	}
+	var shardErrs []error
+	for shard, shardReport := range report {
+		if shardReport.Err != nil {
+			log.Println("WARNING: unable to load camunda incidents of shard", shard, shardReport.Err)
+			shardErrs = append(shardErrs, shardReport.Err)
+		}
+		if metrics != nil {
+			metrics.NotifyCamundaPoll(shard, shardReport.Incidents, shardReport.Duration, shardReport.Err)
+		}
+	}
+	for _, incident := range incidents {
+		if ctx.Err() != nil {
+			break
+		}
+		err = ctrl.CreateIncident(pollCtx, messages.Incident{
+			Id:                  incident.Id,
+			MsgVersion:          3,
+			ExternalTaskId:      incident.ActivityId,
+			ActivityId:          incident.ActivityId,
+			ProcessInstanceId:   incident.ProcessInstanceId,
+			ProcessDefinitionId: incident.ProcessDefinitionId,
+			WorkerId:            "process-incident-worker",
+			ErrorMessage:        incident.IncidentMessage,
+			Time:                time.Now(),
+			TenantId:            incident.TenantId,
+		})
+		if err != nil {
+			log.Println("WARNING: unable to handle camunda incidents", err)
+			continue
+		}
+	}
+	tracing.EndSpan(span, errors.Join(shardErrs...))
+}
@@ -19,6 +19,8 @@ package configuration
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
 	"github.com/segmentio/kafka-go"
 	"os"
 	"reflect"
@@ -28,20 +30,80 @@ import (
 )
 
 type Config struct {
-	MetricsPort                    string                         `json:"metrics_port"`
-	ShardsDb                       string                         `json:"shards_db"`
-	KafkaUrl                       string                         `json:"kafka_url"`
-	KafkaConsumerGroup             string                         `json:"kafka_consumer_group"`
-	KafkaIncidentTopic             string                         `json:"kafka_incident_topic"`
-	Debug                          bool                           `json:"debug"`
-	MongoUrl                       string                         `json:"mongo_url"`
-	MongoDatabaseName              string                         `json:"mongo_database_name"`
-	MongoIncidentCollectionName    string                         `json:"mongo_incident_collection_name"`
-	MongoOnIncidentCollectionName  string                         `json:"mongo_on_incident_collection_name"`
-	TopicConfigMap                 map[string][]kafka.ConfigEntry `json:"topic_config_map"`
-	NotificationUrl                string                         `json:"notification_url"`
-	DeveloperNotificationUrl       string                         `json:"developer_notification_url"`
-	CamundaIncidentRequestInterval string                         `json:"camunda_incident_request_interval"`
+	MetricsPort                    string                                      `json:"metrics_port"`
+	AdminApiPort                   string                                      `json:"admin_api_port"`             //http api for on-incident handlers; "" or "-" disables the api
+	AdminApiToken                  string                                      `json:"admin_api_token"`            //bearer token with access to the handlers of all tenants
+	AdminApiJwtTenantClaim         string                                      `json:"admin_api_jwt_tenant_claim"` //jwt claim (e.g. "sub") of bearer tokens with access to the handlers of one tenant; "" disables jwt access
+	AdminApiJwtPublicKey           string                                      `json:"admin_api_jwt_public_key"`   //pem encoded rsa or ecdsa key to verify jwts; required with admin_api_jwt_tenant_claim
+	ShardsDb                       string                                      `json:"shards_db"`
+	KafkaUrl                       string                                      `json:"kafka_url"`
+	KafkaBrokers                   []string                                    `json:"kafka_brokers"` //bootstrap brokers; if empty, KafkaUrl is used (may be comma separated)
+	KafkaTls                       bool                                        `json:"kafka_tls"`
+	KafkaTlsCaFile                 string                                      `json:"kafka_tls_ca_file"`   //pem; system CAs are used if empty
+	KafkaTlsCertFile               string                                      `json:"kafka_tls_cert_file"` //pem client certificate
+	KafkaTlsKeyFile                string                                      `json:"kafka_tls_key_file"`
+	KafkaTlsInsecureSkipVerify     bool                                        `json:"kafka_tls_insecure_skip_verify"`
+	KafkaSaslMechanism             string                                      `json:"kafka_sasl_mechanism"` //"plain", "scram-sha-256" or "scram-sha-512"; "" or "-" disables sasl
+	KafkaSaslUser                  string                                      `json:"kafka_sasl_user"`
+	KafkaSaslPassword              string                                      `json:"kafka_sasl_password"`
+	KafkaConsumerGroup             string                                      `json:"kafka_consumer_group"`
+	KafkaIncidentTopic             string                                      `json:"kafka_incident_topic"`
+	KafkaDeadLetterTopic           string                                      `json:"kafka_dead_letter_topic"`      //unparseable messages and messages that fail longer than KafkaMessageRetryTimeout are moved to this topic; "" or "-" drops unparseable messages and stops the worker on failing messages
+	KafkaMessageRetryTimeout       string                                      `json:"kafka_message_retry_timeout"`  //defaults to "10m"
+	KafkaConsumerWorkers           int64                                       `json:"kafka_consumer_workers"`       //messages handled in parallel (including messages waiting for a previous message of the same process-instance, which are handled in order); defaults to 1
+	KafkaIncidentHandledTopic      string                                      `json:"kafka_incident_handled_topic"` //topic for messages.IncidentHandledEvent; "" or "-" disables the events
+	OutboxPublishInterval          string                                      `json:"outbox_publish_interval"`      //interval in which stored events are published to kafka; defaults to "1s"
+	Debug                          bool                                        `json:"debug"`
+	MongoUrl                       string                                      `json:"mongo_url"`
+	MongoDatabaseName              string                                      `json:"mongo_database_name"`
+	MongoIncidentCollectionName    string                                      `json:"mongo_incident_collection_name"`
+	MongoOnIncidentCollectionName  string                                      `json:"mongo_on_incident_collection_name"`
+	MongoSuspensionCollectionName  string                                      `json:"mongo_suspension_collection_name"`
+	MongoOutboxCollectionName      string                                      `json:"mongo_outbox_collection_name"`
+	MongoSagaCollectionName        string                                      `json:"mongo_saga_collection_name"`
+	IncidentSagaRetention          string                                      `json:"incident_saga_retention"` //duration finished incident sagas are kept to recognize redelivered incidents; defaults to "24h"
+	TopicConfigMap                 map[string][]kafka.ConfigEntry              `json:"topic_config_map"`
+	TopicPartitions                map[string]int                              `json:"topic_partitions"`          //partitions of created topics by topic name or prefix; defaults to 1
+	TopicReplicationFactors        map[string]int                              `json:"topic_replication_factors"` //replication factor of created topics by topic name or prefix; defaults to 1
+	TopicInitMode                  string                                      `json:"topic_init_mode"`           //"create" (default) creates missing topics, "update" additionally sets TopicConfigMap on existing topics, "check" only checks that topics exist and match
+	NotificationUrl                string                                      `json:"notification_url"`
+	DeveloperNotificationUrl       string                                      `json:"developer_notification_url"`
+	ShutdownTimeout                string                                      `json:"shutdown_timeout"` //max wait for in-flight incidents on shutdown; defaults to "30s"
+	CamundaIncidentRequestInterval string                                      `json:"camunda_incident_request_interval"`
+	CamundaIncidentType            string                                      `json:"camunda_incident_type"`             //polled incident type (e.g. "failedExternalTask"); "" polls all types
+	CamundaIncidentMaxAge          string                                      `json:"camunda_incident_max_age"`          //only incidents created within this duration are polled; "" or "-" polls all open incidents
+	CamundaIncidentPageSize        int64                                       `json:"camunda_incident_page_size"`        //incidents per request; defaults to 100
+	CamundaPollLeaderElection      bool                                        `json:"camunda_poll_leader_election"`      //only the replica holding an advisory lock in the shards database polls camunda
+	CamundaPollLeaderInterval      string                                      `json:"camunda_poll_leader_interval"`      //interval of election attempts and leadership checks; defaults to "10s"
+	CamundaTimeout                 string                                      `json:"camunda_timeout"`                   //per request attempt; defaults to "10s"
+	CamundaRetries                 int64                                       `json:"camunda_retries"`                   //retries of idempotent requests on network errors and 5xx responses
+	CamundaRetryWait               string                                      `json:"camunda_retry_wait"`                //base of the exponential, randomized wait between retries; defaults to "500ms"
+	CamundaRetryMaxWait            string                                      `json:"camunda_retry_max_wait"`            //defaults to "5s"
+	CamundaBreakerThreshold        int64                                       `json:"camunda_breaker_threshold"`         //consecutive failures that open the circuit breaker of a shard; 0 disables the breaker
+	CamundaBreakerOpenDuration     string                                      `json:"camunda_breaker_open_duration"`     //incidents of the shard are put on hold while the breaker is open; defaults to "30s"
+	IncidentRules                  []messages.IncidentRule                     `json:"incident_rules"`                    //checked in order after the rules of the on-incident handler; the first match decides the action
+	DryRun                         bool                                        `json:"dry_run"`                           //observe only: incidents are stored and logged, but no process is stopped or started and no notification is sent
+	DryRunTenants                  []string                                    `json:"dry_run_tenants"`                   //like DryRun but only for the listed tenants
+	IncidentDedupWindow            string                                      `json:"incident_dedup_window"`             //duration in which only one incident per dedup key is handled; defaults to "5m"; "-" disables deduplication
+	IncidentDedupKey               string                                      `json:"incident_dedup_key"`                //"instance" (default), "definition" or "fingerprint" (definition and error message)
+	IncidentDedupMemcachedUrls     []string                                    `json:"incident_dedup_memcached_urls"`     //shared l2 cache; must be set (or IncidentDedupRedisUrl) if the worker is scaled
+	IncidentDedupRedisUrl          string                                      `json:"incident_dedup_redis_url"`          //shared l2 cache as redis url (e.g. redis://localhost:6379/0)
+	IncidentStormThreshold         int64                                       `json:"incident_storm_threshold"`          //incidents of one process-definition within IncidentStormWindow that cause the suspension of the definition; 0 disables the detection
+	IncidentStormWindow            string                                      `json:"incident_storm_window"`             //duration; defaults to "1m"
+	NotificationDigestWindow       string                                      `json:"notification_digest_window"`        //duration in which incident notifications per user and process-definition are aggregated to one digest; "" or "-" disables digests
+	NotificationDigestOptOutUsers  []string                                    `json:"notification_digest_opt_out_users"` //users that are notified immediately
+	NotificationTemplates          map[string]map[string]notification.Template `json:"notification_templates"`            //locale -> template name -> go text/template for title and message; overrides notification.DefaultTemplates
+	NotificationTemplatesDir       string                                      `json:"notification_templates_dir"`        //optional dir with <locale>.json files containing template name -> template maps
+	NotificationUserLocales        map[string]string                           `json:"notification_user_locales"`         //user/tenant -> locale
+	NotificationDefaultLocale      string                                      `json:"notification_default_locale"`       //defaults to "en"
+	NotificationDeepLinks          map[string]string                           `json:"notification_deep_links"`           //link name -> url template; available in notification templates as {{.Links.<name>}}
+	NotificationChannels           map[string]notification.ChannelConfig       `json:"notification_channels"`             //channel name -> channel; the channels "platform" and "developer" are created from notification_url and developer_notification_url
+	NotificationDefaultChannels    []string                                    `json:"notification_default_channels"`     //used if neither the handler nor the tenant selects channels; defaults to all configured channels of notification_url and developer_notification_url
+	NotificationTenantChannels     map[string][]string                         `json:"notification_tenant_channels"`      //tenant -> channel names
+	TracingExporter                string                                      `json:"tracing_exporter"`                  //"otlp", "stdout" or "file"; "" or "-" disables tracing
+	TracingOtlpEndpoint            string                                      `json:"tracing_otlp_endpoint"`             //otlp over http (e.g. http://otel-collector:4318); if empty, the OTEL_EXPORTER_OTLP_... environment variables are used
+	TracingFile                    string                                      `json:"tracing_file"`                      //target of the "file" exporter
+	TracingSampleRatio             float64                                     `json:"tracing_sample_ratio"`              //share (0-1] of recorded traces started by the worker, 0 records every trace; continued traces follow the sampling decision of their parent
 }
 
 // loads config from json in location and used environment variables (e.g ZookeeperUrl --> ZOOKEEPER_URL)
@@ -98,14 +160,24 @@ func handleEnvironmentVars(config *Config) {
 				f, _ := strconv.ParseFloat(envValue, 64)
 				configValue.FieldByName(fieldName).SetFloat(f)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Slice && configValue.FieldByName(fieldName).Type().Elem().Kind() != reflect.String {
+				err := json.Unmarshal([]byte(envValue), configValue.FieldByName(fieldName).Addr().Interface())
+				if err != nil {
+					fmt.Println("ERROR: unable to parse environment variable as json: ", envName, err)
+				}
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
 				val := []string{}
 				for _, element := range strings.Split(envValue, ",") {
 					val = append(val, strings.TrimSpace(element))
 				}
 				configValue.FieldByName(fieldName).Set(reflect.ValueOf(val))
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Map {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Map && configValue.FieldByName(fieldName).Type() != reflect.TypeOf(map[string]string{}) {
+				err := json.Unmarshal([]byte(envValue), configValue.FieldByName(fieldName).Addr().Interface())
+				if err != nil {
+					fmt.Println("ERROR: unable to parse environment variable as json: ", envName, err)
+				}
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Map {
 				value := map[string]string{}
 				for _, element := range strings.Split(envValue, ",") {
 					keyVal := strings.Split(element, ":")
@@ -19,47 +19,123 @@ package controller
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	developerNotifications "github.com/SENERGY-Platform/developer-notifications/pkg/client"
+	"errors"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
 	"github.com/SENERGY-Platform/service-commons/pkg/cache"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"log/slog"
 	"os"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Controller struct {
+	ctx                   context.Context
 	config                configuration.Config
 	camunda               interfaces.Camunda
 	db                    interfaces.Database
 	metrics               Metric
-	devNotifications      developerNotifications.Client
+	notifiers             map[string]notification.Notifier
+	defaultChannels       []string
 	logger                *slog.Logger
 	handledIncidentsCache *cache.Cache
+	dedupWindow           time.Duration
+	stormDetector         *IncidentRateDetector
+	digest                *NotificationDigest
+	templates             *notification.Templates
 	mux                   TopicMutex
+	deadLetterReplay      func() (count int, err error)
+	deadLetterReplaying   atomic.Bool
+	owner                 string          //identifies this replica as owner of incident sagas
+	background            *sync.WaitGroup //saga timers, continued sagas, digests and dead-letter replays
 }
 
 type Metric interface {
 	NotifyIncidentMessage()
+	NotifySuppressedDuplicateIncident(dedupKey string)
+	NotifyDedupCacheLookup(hit bool)
+	NotifyMessageParseError(version string) //version is "unknown" if the msg_version could not be read
+	ObserveGetOnIncident(duration time.Duration)
+	NotifyCamundaAction(action string, result string)
+	NotifyNotification(channel string, result string)
+	ObserveTopicMutexWait(lock string, duration time.Duration)
 }
 
+// results of Metric.NotifyCamundaAction and Metric.NotifyNotification
+const (
+	MetricResultSuccess        = "success"
+	MetricResultError          = "error"
+	MetricResultUnavailable    = "unavailable"
+	MetricResultUnknownChannel = "unknown_channel"
+)
+
+// locks of Metric.ObserveTopicMutexWait
+const (
+	MetricLockIncident = "incident"
+	MetricLockSaga     = "saga"
+)
+
 func New(ctx context.Context, config configuration.Config, camunda interfaces.Camunda, db interfaces.Database, m Metric) (ctrl *Controller, err error) {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	logger := slog.New(tracing.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
 	if info, ok := debug.ReadBuildInfo(); ok {
 		logger = logger.With("go-module", info.Path)
 	}
-	c, err := cache.New(cache.Config{}) //if the worker is scaled, the l2 must be configured with a shared memcached
+	err = ValidateIncidentRules(config.IncidentRules)
+	if err != nil {
+		return nil, err
+	}
+	dedupWindow, err := getDedupWindow(config)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newDedupCache(config) //if the worker is scaled, the l2 must be configured with a shared memcached or redis
+	if err != nil {
+		return nil, err
+	}
+	templates, err := notification.NewTemplates(notification.TemplatesConfig{
+		Templates:     config.NotificationTemplates,
+		Dir:           config.NotificationTemplatesDir,
+		UserLocales:   config.NotificationUserLocales,
+		DefaultLocale: config.NotificationDefaultLocale,
+		Links:         config.NotificationDeepLinks,
+	})
 	if err != nil {
 		return nil, err
 	}
-	ctrl = &Controller{config: config, camunda: camunda, db: db, metrics: m, logger: logger, handledIncidentsCache: c}
-	if config.DeveloperNotificationUrl != "" && config.DeveloperNotificationUrl != "-" {
-		ctrl.devNotifications = developerNotifications.New(config.DeveloperNotificationUrl)
+	ctrl = &Controller{ctx: ctx, config: config, camunda: camunda, db: db, metrics: m, logger: logger, handledIncidentsCache: c, dedupWindow: dedupWindow, templates: templates, owner: uuid.NewString(), background: &sync.WaitGroup{}}
+	if config.IncidentStormThreshold > 0 {
+		stormWindow := DefaultIncidentStormWindow
+		if config.IncidentStormWindow != "" {
+			stormWindow, err = time.ParseDuration(config.IncidentStormWindow)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ctrl.stormDetector = NewIncidentRateDetector(stormWindow)
+	}
+	if config.NotificationDigestWindow != "" && config.NotificationDigestWindow != "-" {
+		digestWindow, err := time.ParseDuration(config.NotificationDigestWindow)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.digest = NewNotificationDigest(ctx, ctrl.background, digestWindow, ctrl.createNotification, func(msg notification.Message) error {
+			return ctrl.notify(context.Background(), msg)
+		}, ctrl.continueSagas)
+	}
+	ctrl.notifiers, ctrl.defaultChannels, err = newNotifiers(ctx, config)
+	if err != nil {
+		return nil, err
 	}
 	return ctrl, nil
 }
@@ -74,20 +150,53 @@ func getMsgVersion(msg []byte) (version int64, err error) {
 	return wrapper.MsgVersion, err
 }
 
-func (this *Controller) HandleIncidentMessage(msg []byte) error {
+// GetMessageOrderingKey returns the process-instance of incident messages; messages with the same key are handled in order.
+// other messages (e.g. handler or definition commands) return "" and are handled after all previously received messages
+func (this *Controller) GetMessageOrderingKey(msg []byte) string {
 	version, err := getMsgVersion(msg)
 	if err != nil {
-		this.logger.Error("unable to parse msg -> ignore", "snrgy-log-type", "error", "error", err.Error(), "msg", string(msg))
-		return nil
+		return ""
 	}
 	if version == 1 || version == 2 {
 		incident := messages.Incident{}
 		err = json.Unmarshal(msg, &incident)
 		if err != nil {
-			this.logger.Error("unable to parse msg -> ignore", "snrgy-log-type", "error", "error", err.Error(), "msg", string(msg))
-			return nil
+			return ""
 		}
-		err = this.CreateIncident(incident)
+		return incident.ProcessInstanceId
+	}
+	if version == 3 {
+		command := messages.KafkaIncidentsCommand{}
+		err = json.Unmarshal(msg, &command)
+		if err != nil {
+			return ""
+		}
+		if (command.Command == "PUT" || command.Command == "POST") && command.Incident != nil {
+			return command.Incident.ProcessInstanceId
+		}
+		if command.Command == "DELETE" && command.ProcessDefinitionId == "" {
+			return command.ProcessInstanceId
+		}
+	}
+	return ""
+}
+
+func (this *Controller) HandleIncidentMessage(ctx context.Context, msg []byte) error {
+	version, err := getMsgVersion(msg)
+	if err != nil {
+		this.logger.Error("unable to parse msg", "snrgy-log-type", "error", "error", err.Error(), "msg", string(msg))
+		this.metrics.NotifyMessageParseError("unknown")
+		return errors.Join(interfaces.ErrInvalidMessage, err)
+	}
+	if version == 1 || version == 2 {
+		incident := messages.Incident{}
+		err = json.Unmarshal(msg, &incident)
+		if err != nil {
+			this.logger.Error("unable to parse msg", "snrgy-log-type", "error", "error", err.Error(), "msg", string(msg))
+			this.metrics.NotifyMessageParseError(strconv.FormatInt(version, 10))
+			return errors.Join(interfaces.ErrInvalidMessage, err)
+		}
+		err = this.CreateIncident(ctx, incident)
 		if err != nil {
 			this.logger.Error("unable to hande incident create", "snrgy-log-type", "error", "error", err.Error(), "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "incident-msg", incident.ErrorMessage)
 		}
@@ -97,13 +206,14 @@ func (this *Controller) HandleIncidentMessage(msg []byte) error {
 		command := messages.KafkaIncidentsCommand{}
 		err = json.Unmarshal(msg, &command)
 		if err != nil {
-			this.logger.Error("unable to parse msg -> ignore", "snrgy-log-type", "error", "error", err.Error(), "msg", string(msg))
-			return nil
+			this.logger.Error("unable to parse msg", "snrgy-log-type", "error", "error", err.Error(), "msg", string(msg))
+			this.metrics.NotifyMessageParseError(strconv.FormatInt(version, 10))
+			return errors.Join(interfaces.ErrInvalidMessage, err)
 		}
 		if command.Command == "PUT" || command.Command == "POST" {
 			if command.Incident != nil {
 				command.Incident.MsgVersion = command.MsgVersion
-				err = this.CreateIncident(*command.Incident)
+				err = this.CreateIncident(ctx, *command.Incident)
 				if err != nil {
 					this.logger.Error("unable to hande incident PUT/POST", "snrgy-log-type", "error", "error", err.Error(), "user", command.Incident.TenantId, "process-definition-id", command.Incident.ProcessDefinitionId, "incident-msg", command.Incident.ErrorMessage)
 				}
@@ -126,7 +236,38 @@ func (this *Controller) HandleIncidentMessage(msg []byte) error {
 				return err
 			}
 		}
+		if command.Command == "LIFT_SUSPENSION" && command.ProcessDefinitionId != "" {
+			err = this.LiftDefinitionSuspension(ctx, command.ProcessDefinitionId)
+			if err != nil {
+				this.logger.Error("unable to hande incident LIFT_SUSPENSION", "snrgy-log-type", "error", "error", err.Error(), "process-definition-id", command.ProcessDefinitionId)
+			}
+			return err
+		}
+		if (command.Command == "ACK" || command.Command == "RESOLVE") && command.IncidentId != "" {
+			status := messages.IncidentStatusAcknowledged
+			if command.Command == "RESOLVE" {
+				status = messages.IncidentStatusResolved
+			}
+			_, err = this.SetIncidentStatus(command.IncidentId, status, command.Actor, command.Comment)
+			if errors.Is(err, ErrIncidentNotFound) || errors.Is(err, ErrInvalidIncidentStatusTransition) {
+				this.logger.Error("invalid incident "+command.Command+" -> ignore", "snrgy-log-type", "warning", "error", err.Error(), "incident-id", command.IncidentId, "actor", command.Actor)
+				return nil
+			}
+			if err != nil {
+				this.logger.Error("unable to hande incident "+command.Command, "snrgy-log-type", "error", "error", err.Error(), "incident-id", command.IncidentId)
+			}
+			return err
+		}
+		if command.Command == "REPLAY_DEAD_LETTERS" {
+			this.ReplayDeadLetters()
+			return nil
+		}
 		if command.Command == "HANDLER" && command.Handler != nil {
+			err = ValidateOnIncident(*command.Handler)
+			if err != nil {
+				this.logger.Error("invalid incident HANDLER -> ignore", "snrgy-log-type", "error", "error", err.Error(), "process-definition-id", command.Handler.ProcessDefinitionId)
+				return nil
+			}
 			err = this.SetOnIncidentHandler(*command.Handler)
 			if err != nil {
 				this.logger.Error("unable to hande incident HANDLER", "snrgy-log-type", "error", "error", err.Error(), "process-definition-id", command.Handler.ProcessDefinitionId)
@@ -137,72 +278,401 @@ func (this *Controller) HandleIncidentMessage(msg []byte) error {
 	return nil
 }
 
-func (this *Controller) CreateIncident(incident messages.Incident) (err error) {
-	topic := incident.ProcessDefinitionId + "+" + incident.ProcessInstanceId
-	this.mux.Lock(topic)
+func (this *Controller) CreateIncident(ctx context.Context, incident messages.Incident) (err error) {
+	if this.dedupWindow <= 0 {
+		topic := getDedupKey(DedupKeyInstance, incident)
+		this.lock(MetricLockIncident, topic)
+		defer this.mux.Unlock(topic)
+		return this.createIncident(ctx, incident)
+	}
+	topic := getDedupKey(this.config.IncidentDedupKey, incident)
+	this.lock(MetricLockIncident, topic)
 	defer this.mux.Unlock(topic)
-	//for every process instance an incident may only be handled once every 5 min
-	//use the cache.Use method to do incident handling, only if the process instance is not found in cache
-	//incident.ProcessInstanceId should be enough as key but existing tests would fail, so the incident.ProcessDefinitionId is added
+	//for every dedup key (default: process instance) an incident may only be handled once every dedup window (default: 5 min)
+	//use the cache.Use method to do incident handling, only if the key is not found in cache
+	handled := false
 	_, err = cache.Use[string](this.handledIncidentsCache, topic, func() (string, error) {
-		return "", this.createIncident(incident)
-	}, cache.NoValidation, 5*time.Minute)
+		handled = true
+		return "", this.createIncident(ctx, incident)
+	}, cache.NoValidation, this.dedupWindow)
+	if err == nil && !handled {
+		this.metrics.NotifySuppressedDuplicateIncident(this.config.IncidentDedupKey)
+	}
+	if err == nil {
+		this.metrics.NotifyDedupCacheLookup(!handled)
+	}
 	return err
 }
 
-func (this *Controller) createIncident(incident messages.Incident) (err error) {
+// lock locks topic in this.mux and reports the waiting time
+func (this *Controller) lock(lock string, topic string) {
+	start := time.Now()
+	this.mux.Lock(topic)
+	this.metrics.ObserveTopicMutexWait(lock, time.Since(start))
+}
+
+func (this *Controller) createIncident(ctx context.Context, incident messages.Incident) (err error) {
 	this.metrics.NotifyIncidentMessage()
+	existingSaga, exists, err := this.db.GetIncidentSaga(getSagaId(incident))
+	if err != nil {
+		return err
+	}
+	if exists {
+		if existingSaga.Finished {
+			this.logger.Info("process-incident already handled -> ignore", "snrgy-log-type", "process-incident", "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+			return nil
+		}
+		return this.continueSaga(ctx, existingSaga.Id)
+	}
+	getOnIncidentStart := time.Now()
+	_, span := tracing.StartSpan(ctx, "mongo GetOnIncident", trace.WithAttributes(attribute.String("process-definition-id", incident.ProcessDefinitionId)))
 	handling, registeredHandling, err := this.db.GetOnIncident(incident.ProcessDefinitionId)
+	tracing.EndSpan(span, err)
+	this.metrics.ObserveGetOnIncident(time.Since(getOnIncidentStart))
 	if err != nil {
 		log.Println("ERROR: ", err)
 		debug.PrintStack()
 		return err
 	}
-	name, err := this.camunda.GetProcessName(incident.ProcessDefinitionId, incident.TenantId)
+	rule, ruleMatched := this.classifyIncident(incident, handling)
+	if ruleMatched {
+		incident.RuleAction = rule.Action
+	}
+	if rule.Action == messages.IncidentActionIgnore {
+		this.logger.Info("process-incident ignored by rule", "snrgy-log-type", "process-incident", "error", incident.ErrorMessage, "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+		this.publishIncidentHandled(incident, []messages.IncidentHandledAction{{Action: messages.HandledActionIgnore}}, "")
+		return nil
+	}
+	if registeredHandling && handling.RestartState != nil && handling.RestartState.Disabled {
+		if _, retried := findRestartDecision(handling.RestartState, incident.Id); !retried {
+			registeredHandling = false
+		}
+	}
+	newOpenIncidentStatus(&incident)
+	dryRun := this.IsDryRun(incident.TenantId)
+	if dryRun {
+		incident.DryRun = true
+	}
+	saga := &messages.IncidentSaga{Id: getSagaId(incident), Handling: handling, CreatedAt: time.Now()}
+	sendNotification := func(msg notification.Message) {
+		if dryRun {
+			incident.DryRunActions = append(incident.DryRunActions, "notify "+msg.UserId+": "+msg.Title)
+			return
+		}
+		if len(msg.Channels) == 0 {
+			msg.Channels = handling.NotificationChannels
+		}
+		saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.HandledActionNotify, Target: msg.UserId, Notification: toSagaNotification(msg)})
+	}
+	notify := !registeredHandling || handling.Notify
+	stop := true
+	restart := registeredHandling && handling.Restart
+	switch rule.Action {
+	case messages.IncidentActionStop:
+		restart = false
+	case messages.IncidentActionRestart:
+		restart = true
+	case messages.IncidentActionNotifyOnly:
+		notify = true
+		stop = false
+		restart = false
+	case messages.IncidentActionEscalate:
+		notify = true
+		restart = false
+	}
+	decision := RestartDecision{}
+	if restart {
+		decision, err = this.checkRestartBudget(handling, incident.Id, dryRun)
+		if err != nil {
+			return err
+		}
+		restart = decision.Restart
+	}
+	resume := restart && handling.Mode == messages.OnIncidentModeResume
+	name, err := this.camunda.GetProcessName(ctx, incident.ProcessDefinitionId, incident.TenantId)
+	if errors.Is(err, interfaces.ErrShardUnavailable) {
+		//retried by the consumer when the circuit breaker of the shard allows calls again
+		return err
+	}
 	if err != nil {
 		this.logger.Error("unable to get process name", "snrgy-log-type", "warning", "error", err.Error())
 		incident.DeploymentName = incident.ProcessDefinitionId
 	} else {
 		incident.DeploymentName = name
 	}
-	this.logger.Info("process-incident", "snrgy-log-type", "process-incident", "error", incident.ErrorMessage, "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+	this.logger.InfoContext(ctx, "process-incident", "snrgy-log-type", "process-incident", "error", incident.ErrorMessage, "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+	suspended, err := this.checkIncidentStorm(ctx, &incident, dryRun, sendNotification, saga)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		//the user has been notified about the suspension of the definition
+		notify = false
+	}
+	data := notification.TemplateData{
+		Incident:       incident,
+		DeploymentName: incident.DeploymentName,
+		Restart:        restart,
+		Resume:         resume,
+	}
+	if stop && !resume {
+		data.Actions = append(data.Actions, "stop")
+	}
+	if resume {
+		data.Actions = append(data.Actions, "resume")
+	} else if restart {
+		data.Actions = append(data.Actions, "restart")
+	}
+	if rule.Action == messages.IncidentActionEscalate {
+		for _, userId := range rule.EscalateTo {
+			sendNotification(this.createNotification(notification.TemplateEscalation, userId, data))
+		}
+	}
 	if incident.TenantId != "" {
-		if !registeredHandling || handling.Notify {
-			msg := notification.Message{
-				UserId:  incident.TenantId,
-				Title:   "Process-Incident in " + incident.DeploymentName,
-				Message: incident.ErrorMessage,
-				Topic:   notification.Topic,
+		if notify {
+			templateName := notification.TemplateIncident
+			if rule.Action == messages.IncidentActionEscalate {
+				templateName = notification.TemplateIncidentEscalated
 			}
-			if registeredHandling && handling.Restart {
-				msg.Message = msg.Message + "\n\nprocess will be restarted"
+			msg := this.createNotification(templateName, incident.TenantId, data)
+			msg.Channels = handling.NotificationChannels
+			if this.useDigest(incident, handling) && rule.Action != messages.IncidentActionEscalate {
+				if dryRun {
+					incident.DryRunActions = append(incident.DryRunActions, "add to notification digest of "+msg.UserId+": "+msg.Title)
+				} else {
+					saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.HandledActionDigest, Target: msg.UserId, Notification: toSagaNotification(msg)})
+				}
+			} else {
+				sendNotification(msg)
 			}
-			this.Notify(msg)
+		}
+		if decision.Exhausted && decision.Action != messages.OnRestartBudgetExhaustedStop {
+			data.HandlerDisabled = decision.Action == messages.OnRestartBudgetExhaustedDisable
+			sendNotification(this.createNotification(notification.TemplateRestartBudgetExhausted, incident.TenantId, data))
+		}
+	}
+	if dryRun {
+		if stop && !resume {
+			incident.DryRunActions = append(incident.DryRunActions, "stop process-instance")
+		}
+		if resume {
+			incident.DryRunActions = append(incident.DryRunActions, "resume process-instance after "+decision.Delay.String())
+		} else if restart {
+			incident.DryRunActions = append(incident.DryRunActions, "restart process after "+decision.Delay.String())
+		}
+		this.logger.InfoContext(ctx, "dry-run process-incident", "snrgy-log-type", "process-incident", "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId, "actions", incident.DryRunActions)
+		err = this.saveIncident(ctx, incident)
+		if err == nil {
+			this.publishIncidentHandled(incident, nil, "")
+		}
+		return err
+	}
+	if stop && !resume {
+		saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.HandledActionStop})
+	}
+	saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.SagaStepSave})
+	if resume {
+		saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.HandledActionResume, NotBefore: time.Now().Add(decision.Delay)})
+	} else if restart {
+		//the variables are read before the instance is stopped
+		variables, err := this.getRestartVariables(ctx, incident, handling)
+		if err != nil {
+			return err
+		}
+		saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.HandledActionRestart, Variables: variables, NotBefore: time.Now().Add(decision.Delay)})
+	}
+	saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.SagaStepPublish})
+	saga.Incident = incident
+	err = this.saveSaga(saga, time.Now())
+	if errors.Is(err, interfaces.ErrSagaClaimed) {
+		this.logger.Info("process-incident handled by another replica -> ignore", "snrgy-log-type", "process-incident", "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return this.continueSaga(ctx, saga.Id)
+}
+
+// classifyIncident checks the rules of the handler and then the global rules
+func (this *Controller) classifyIncident(incident messages.Incident, handling messages.OnIncident) (rule messages.IncidentRule, found bool) {
+	rule, found, err := MatchIncidentRule(append(append([]messages.IncidentRule{}, handling.Rules...), this.config.IncidentRules...), incident)
+	if err != nil {
+		this.logger.Error("unable to check incident rules -> use default handling", "snrgy-log-type", "warning", "error", err.Error(), "process-definition-id", incident.ProcessDefinitionId)
+		return messages.IncidentRule{}, false
+	}
+	return rule, found
+}
+
+// checkIncidentStorm suspends the process-definition if it produces more incidents than configured in IncidentStormThreshold
+// and returns true if the definition is suspended.
+// the suspension is recorded with the id of the incident that triggered it before the saga step suspends the definition in camunda;
+// a retry of this incident (e.g. after a crash before the saga has been saved) repeats the step and the notification
+func (this *Controller) checkIncidentStorm(ctx context.Context, incident *messages.Incident, dryRun bool, sendNotification func(msg notification.Message), saga *messages.IncidentSaga) (suspended bool, err error) {
+	if this.stormDetector == nil {
+		return false, nil
+	}
+	suspension, suspended, err := this.db.GetDefinitionSuspension(incident.ProcessDefinitionId)
+	if err != nil {
+		this.logger.Error("unable to check process-definition suspension", "snrgy-log-type", "warning", "error", err.Error(), "process-definition-id", incident.ProcessDefinitionId)
+		return false, nil
+	}
+	if suspended && suspension.IncidentId != incident.Id {
+		return true, nil
+	}
+	if !suspended {
+		count := this.stormDetector.Add(incident.ProcessDefinitionId, incident.Id, time.Now())
+		if int64(count) < this.config.IncidentStormThreshold {
+			return false, nil
+		}
+		if dryRun {
+			incident.DryRunActions = append(incident.DryRunActions, "suspend process-definition")
+			return true, nil
+		}
+		suspension = messages.DefinitionSuspension{
+			ProcessDefinitionId: incident.ProcessDefinitionId,
+			TenantId:            incident.TenantId,
+			DeploymentName:      incident.DeploymentName,
+			SuspendedAt:         time.Now(),
+			IncidentCount:       count,
+			Window:              this.stormDetector.window.String(),
+			IncidentId:          incident.Id,
+		}
+		err = this.db.SaveDefinitionSuspension(suspension)
+		if err != nil {
+			return false, err
 		}
 	}
-	err = this.camunda.StopProcessInstance(incident.ProcessInstanceId, incident.TenantId)
+	this.logger.WarnContext(ctx, "process-definition suspended after incident storm", "snrgy-log-type", "process-incident", "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "incident-count", suspension.IncidentCount)
+	saga.Steps = append(saga.Steps, messages.SagaStep{Action: messages.HandledActionSuspend, Target: incident.ProcessDefinitionId})
+	if incident.TenantId != "" {
+		sendNotification(this.createNotification(notification.TemplateStormSuspension, incident.TenantId, notification.TemplateData{
+			Incident:       *incident,
+			DeploymentName: incident.DeploymentName,
+			Actions:        []string{"suspend"},
+			Count:          suspension.IncidentCount,
+			Window:         suspension.Window,
+		}))
+	}
+	return true, nil
+}
+
+// LiftDefinitionSuspension activates a process-definition suspended by checkIncidentStorm
+func (this *Controller) LiftDefinitionSuspension(ctx context.Context, definitionId string) error {
+	suspension, exists, err := this.db.GetDefinitionSuspension(definitionId)
 	if err != nil {
 		return err
 	}
-	err = this.db.SaveIncident(incident)
+	if !exists {
+		return nil
+	}
+	err = this.camunda.SetProcessDefinitionSuspended(ctx, definitionId, suspension.TenantId, false)
 	if err != nil {
 		return err
 	}
-	if registeredHandling && handling.Restart {
-		err = this.camunda.StartProcess(incident.ProcessDefinitionId, incident.TenantId)
+	if this.stormDetector != nil {
+		this.stormDetector.Reset(definitionId)
+	}
+	return this.db.DeleteDefinitionSuspension(definitionId)
+}
+
+// useDigest checks if the incident notification may be aggregated in a digest or has to be sent immediately
+func (this *Controller) useDigest(incident messages.Incident, handling messages.OnIncident) bool {
+	if this.digest == nil || handling.NotifyImmediately {
+		return false
+	}
+	return !slices.Contains(this.config.NotificationDigestOptOutUsers, incident.TenantId)
+}
+
+// IsDryRun checks if incidents of the tenant may only be observed. in dry-run mode no process is stopped or started and no notification is sent
+func (this *Controller) IsDryRun(tenantId string) bool {
+	if this.config.DryRun {
+		return true
+	}
+	return slices.Contains(this.config.DryRunTenants, tenantId)
+}
+
+// checkRestartBudget decides if the process may be restarted; in dry-run the restart state of the handler is not updated.
+// if another replica has updated the state in the meantime, the decision is repeated with the new state.
+// the decision is recorded in the restart state; a retry of the incident (e.g. after a crash before the saga has been saved) reuses it
+func (this *Controller) checkRestartBudget(handling messages.OnIncident, incidentId string, dryRun bool) (decision RestartDecision, err error) {
+	for attempt := 1; ; attempt++ {
+		if recorded, ok := findRestartDecision(handling.RestartState, incidentId); ok {
+			return recorded, nil
+		}
+		state := messages.RestartState{}
+		if handling.RestartState != nil {
+			state = *handling.RestartState
+		}
+		newState := messages.RestartState{}
+		decision, newState, err = DecideRestart(handling.RestartPolicy, state, time.Now())
+		if err != nil || handling.RestartPolicy == nil || dryRun {
+			return decision, err
+		}
+		newState.Decisions = append(newState.Decisions, messages.RestartStateDecision{
+			IncidentId: incidentId,
+			Restart:    decision.Restart,
+			Delay:      decision.Delay,
+			Exhausted:  decision.Exhausted,
+			Action:     decision.Action,
+		})
+		if len(newState.Decisions) > MaxRestartStateDecisions {
+			newState.Decisions = newState.Decisions[len(newState.Decisions)-MaxRestartStateDecisions:]
+		}
+		updated, err := this.db.UpdateOnIncidentRestartState(handling.ProcessDefinitionId, state.Version, newState)
+		if err != nil || updated {
+			return decision, err
+		}
+		if attempt >= MaxRestartStateUpdateAttempts {
+			return RestartDecision{}, errors.New("restart state of " + handling.ProcessDefinitionId + " is updated concurrently")
+		}
+		var exists bool
+		handling, exists, err = this.db.GetOnIncident(handling.ProcessDefinitionId)
 		if err != nil {
-			this.logger.Error("unable to restart process", "snrgy-log-type", "process-incident", "error", err.Error(), "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
-			if incident.TenantId != "" {
-				this.Notify(notification.Message{
-					UserId:  incident.TenantId,
-					Title:   "ERROR: unable to restart process after incident in: " + incident.DeploymentName,
-					Message: fmt.Sprintf("Restart-Error: %v \n\n Incident: %v \n", err, incident.ErrorMessage),
-					Topic:   notification.Topic,
-				})
-			}
+			return RestartDecision{}, err
+		}
+		if !exists {
+			//handler has been removed in the meantime
+			return RestartDecision{}, nil
 		}
 	}
-	return nil
+}
+
+func findRestartDecision(state *messages.RestartState, incidentId string) (decision RestartDecision, found bool) {
+	if state == nil {
+		return decision, false
+	}
+	for _, recorded := range state.Decisions {
+		if recorded.IncidentId == incidentId {
+			return RestartDecision{Restart: recorded.Restart, Delay: recorded.Delay, Exhausted: recorded.Exhausted, Action: recorded.Action}, true
+		}
+	}
+	return decision, false
+}
+
+// getRestartVariables copies the variables of the failed instance (must be called before the instance is stopped)
+// and overwrites them with the restart variables of the handler; only interfaces.ErrShardUnavailable is returned
+func (this *Controller) getRestartVariables(ctx context.Context, incident messages.Incident, handling messages.OnIncident) (map[string]interface{}, error) {
+	result, err := this.camunda.GetProcessInstanceVariables(ctx, incident.ProcessInstanceId, incident.TenantId)
+	if errors.Is(err, interfaces.ErrShardUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		this.logger.Warn("unable to copy variables of failed process instance", "snrgy-log-type", "warning", "error", err.Error(), "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+		result = map[string]interface{}{}
+	}
+	for key, value := range handling.RestartVariables {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// saveIncident stores the incident in a child span of ctx
+func (this *Controller) saveIncident(ctx context.Context, incident messages.Incident) error {
+	_, span := tracing.StartSpan(ctx, "mongo SaveIncident", trace.WithAttributes(attribute.String("incident-id", incident.Id)))
+	err := this.db.SaveIncident(incident)
+	tracing.EndSpan(span, err)
+	return err
 }
 
 func (this *Controller) DeleteIncidentByProcessInstanceId(id string) error {
@@ -213,26 +683,75 @@ func (this *Controller) DeleteIncidentByProcessDefinitionId(id string) error {
 	return this.db.DeleteByDefinitionId(id)
 }
 
+func ValidateOnIncident(handler messages.OnIncident) error {
+	switch handler.Mode {
+	case "", messages.OnIncidentModeRestart, messages.OnIncidentModeResume:
+	default:
+		return errors.New("unknown on-incident mode: " + handler.Mode)
+	}
+	err := ValidateIncidentRules(handler.Rules)
+	if err != nil {
+		return err
+	}
+	return ValidateRestartPolicy(handler.RestartPolicy)
+}
+
+// SetOnIncidentHandler saves the handler of the kafka HANDLER command with the same rules as the admin api:
+// the restart state is reset and the tenant of the stored handler is kept
 func (this *Controller) SetOnIncidentHandler(handler messages.OnIncident) error {
+	existing, exists, err := this.db.GetOnIncident(handler.ProcessDefinitionId)
+	if err != nil {
+		return err
+	}
+	handler.TenantId = ""
+	if exists {
+		handler.TenantId = existing.TenantId
+	}
+	handler.RestartState = nil
 	return this.db.SaveOnIncident(handler)
 }
 
-func (this *Controller) Notify(msg notification.Message) {
-	_ = notification.Send(this.config.NotificationUrl, msg)
-	if this.devNotifications != nil {
-		go func() {
-			if this.config.Debug {
-				log.Println("DEBUG: send developer-notification")
-			}
-			err := this.devNotifications.SendMessage(developerNotifications.Message{
-				Sender: "github.com/SENERGY-Platform/process-incident-worker",
-				Title:  "Process-Incident-User-Notification",
-				Tags:   []string{"process-incident", "user-notification", msg.UserId},
-				Body:   fmt.Sprintf("Notification For %v\nTitle: %v\nMessage: %v\n", msg.UserId, msg.Title, msg.Message),
-			})
-			if err != nil {
-				log.Println("ERROR: unable to send developer-notification", err)
-			}
-		}()
+// Wait blocks until the background goroutines of the controller (saga timers, continued sagas, digests and dead-letter replays) have returned;
+// it should be called after the ctx of New is done
+func (this *Controller) Wait() {
+	this.background.Wait()
+}
+
+// SetDeadLetterReplay sets the function used by the REPLAY_DEAD_LETTERS command
+func (this *Controller) SetDeadLetterReplay(replay func() (count int, err error)) {
+	this.deadLetterReplay = replay
+}
+
+// ReplayDeadLetters moves the messages of the dead-letter topic back to the incident topic in the background;
+// only one replay runs at a time
+func (this *Controller) ReplayDeadLetters() {
+	if this.deadLetterReplay == nil {
+		this.logger.Error("unable to replay dead-letters: replay not available", "snrgy-log-type", "warning")
+		return
+	}
+	if !this.deadLetterReplaying.CompareAndSwap(false, true) {
+		this.logger.Info("dead-letter replay already running", "snrgy-log-type", "warning")
+		return
+	}
+	this.background.Add(1)
+	go func() {
+		defer this.background.Done()
+		defer this.deadLetterReplaying.Store(false)
+		count, err := this.deadLetterReplay()
+		if err != nil {
+			this.logger.Error("unable to replay dead-letters", "snrgy-log-type", "error", "error", err.Error(), "replayed", count)
+			return
+		}
+		this.logger.Info("dead-letters replayed", "snrgy-log-type", "info", "replayed", count)
+	}()
+}
+
+// createNotification renders the notification template in the locale of the user
+func (this *Controller) createNotification(templateName string, userId string, data notification.TemplateData) notification.Message {
+	msg, err := this.templates.Render(userId, templateName, data)
+	if err != nil {
+		this.logger.Error("unable to render notification template", "snrgy-log-type", "error", "error", err.Error(), "template", templateName, "user", userId)
+		return notification.Message{UserId: userId, Title: "Process-Incident in " + data.DeploymentName, Message: data.Incident.ErrorMessage, Topic: notification.Topic}
 	}
+	return msg
 }
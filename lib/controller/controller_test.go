@@ -0,0 +1,133 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMessageOrderingKey(t *testing.T) {
+	ctrl := &Controller{}
+	cases := map[string]string{
+		`{"msg_version":2,"process_instance_id":"i1"}`:                                   "i1",
+		`{"msg_version":3,"command":"PUT","incident":{"process_instance_id":"i2"}}`:      "i2",
+		`{"msg_version":3,"command":"DELETE","process_instance_id":"i3"}`:                "i3",
+		`{"msg_version":3,"command":"DELETE","process_definition_id":"d1"}`:              "",
+		`{"msg_version":3,"command":"HANDLER","handler":{"process_definition_id":"d1"}}`: "",
+		`{"msg_version":3,"command":"ACK","incident_id":"inc1"}`:                         "",
+		`{"msg_version":3,"command":"LIFT_SUSPENSION","process_definition_id":"d1"}`:     "",
+		`{`: "",
+	}
+	for msg, expected := range cases {
+		if actual := ctrl.GetMessageOrderingKey([]byte(msg)); actual != expected {
+			t.Errorf("%v: %#v != %#v", msg, actual, expected)
+		}
+	}
+}
+
+type dryRunTestDb struct {
+	*sagaTestDb
+}
+
+func (this dryRunTestDb) GetDefinitionSuspension(definitionId string) (messages.DefinitionSuspension, bool, error) {
+	return messages.DefinitionSuspension{}, false, nil
+}
+
+// the fakes panic on calls that are not expected in dry-run (e.g. suspensions or restart state updates)
+func TestDryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := dryRunTestDb{sagaTestDb: &sagaTestDb{
+		handler: messages.OnIncident{ProcessDefinitionId: "d1", Restart: true, Notify: true, RestartPolicy: &messages.RestartPolicy{MaxRestarts: 1}},
+		sagas:   map[string]messages.IncidentSaga{},
+	}}
+	camunda := &sagaTestCamunda{}
+	ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-", DryRunTenants: []string{"user"}, IncidentStormThreshold: 2}, camunda, db, sagaTestMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &sagaTestNotifier{}
+	ctrl.notifiers = map[string]notification.Notifier{"test": notifier}
+	ctrl.defaultChannels = []string{"test"}
+
+	expectedActions := [][]string{
+		{"notify user: ", "stop process-instance", "restart process after 0s"},
+		{"suspend process-definition", "stop process-instance", "restart process after 0s"}, //second incident triggers the storm detection
+	}
+	for i, instance := range []string{"i1", "i2"} {
+		err = ctrl.CreateIncident(ctx, messages.Incident{Id: "incident-" + instance, ProcessDefinitionId: "d1", ProcessInstanceId: instance, TenantId: "user", ErrorMessage: "error", Time: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(db.incidents) != i+1 || !db.incidents[i].DryRun || len(db.incidents[i].DryRunActions) != len(expectedActions[i]) {
+			t.Fatalf("%#v", db.incidents)
+		}
+		for j, action := range db.incidents[i].DryRunActions {
+			if !strings.HasPrefix(action, expectedActions[i][j]) {
+				t.Fatalf("%#v", db.incidents[i].DryRunActions)
+			}
+		}
+	}
+	stops, starts := camunda.counts()
+	if stops != 0 || starts != 0 || camunda.resumes != 0 || notifier.count != 0 || len(db.sagas) != 0 {
+		t.Fatal(stops, starts, camunda.resumes, notifier.count, len(db.sagas))
+	}
+}
+
+type handlerTestDb struct {
+	*sagaTestDb
+}
+
+func (this handlerTestDb) SaveOnIncident(handler messages.OnIncident) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.handler = handler
+	return nil
+}
+
+func TestSetOnIncidentHandler(t *testing.T) {
+	db := handlerTestDb{&sagaTestDb{handler: messages.OnIncident{ProcessDefinitionId: "d1", TenantId: "user1", Notify: true}}}
+	ctrl := &Controller{db: db}
+	err := ctrl.SetOnIncidentHandler(messages.OnIncident{
+		ProcessDefinitionId: "d1",
+		Restart:             true,
+		TenantId:            "user2",
+		RestartState:        &messages.RestartState{Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.handler.TenantId != "user1" || db.handler.RestartState != nil || !db.handler.Restart || db.handler.Notify {
+		t.Fatalf("%#v", db.handler)
+	}
+
+	//new handlers get their tenant through the admin api
+	err = ctrl.SetOnIncidentHandler(messages.OnIncident{ProcessDefinitionId: "d2", TenantId: "user2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.handler.ProcessDefinitionId != "d2" || db.handler.TenantId != "" {
+		t.Fatalf("%#v", db.handler)
+	}
+}
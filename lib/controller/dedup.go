@@ -0,0 +1,83 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/rediscache"
+	"github.com/SENERGY-Platform/service-commons/pkg/cache"
+	"github.com/SENERGY-Platform/service-commons/pkg/cache/memcached"
+	"regexp"
+	"time"
+)
+
+const (
+	DedupKeyInstance    = "instance"    //one handling per process-instance (default)
+	DedupKeyDefinition  = "definition"  //one handling per process-definition
+	DedupKeyFingerprint = "fingerprint" //one handling per process-definition and normalized error message
+)
+
+const DefaultDedupWindow = 5 * time.Minute
+
+func getDedupWindow(config configuration.Config) (time.Duration, error) {
+	if config.IncidentDedupWindow == "" {
+		return DefaultDedupWindow, nil
+	}
+	if config.IncidentDedupWindow == "-" {
+		return 0, nil
+	}
+	return time.ParseDuration(config.IncidentDedupWindow)
+}
+
+func newDedupCache(config configuration.Config) (*cache.Cache, error) {
+	switch config.IncidentDedupKey {
+	case "", DedupKeyInstance, DedupKeyDefinition, DedupKeyFingerprint:
+	default:
+		return nil, errors.New("unknown incident_dedup_key: " + config.IncidentDedupKey)
+	}
+	cacheConfig := cache.Config{}
+	if len(config.IncidentDedupMemcachedUrls) > 0 && config.IncidentDedupRedisUrl != "" {
+		return nil, errors.New("incident_dedup_memcached_urls and incident_dedup_redis_url may not be used together")
+	}
+	if len(config.IncidentDedupMemcachedUrls) > 0 {
+		cacheConfig.L2Provider = memcached.NewProvider(10, 2*time.Second, config.IncidentDedupMemcachedUrls...)
+	}
+	if config.IncidentDedupRedisUrl != "" {
+		cacheConfig.L2Provider = rediscache.NewProvider(config.IncidentDedupRedisUrl, 2*time.Second)
+	}
+	return cache.New(cacheConfig)
+}
+
+var fingerprintNormalization = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9]+`)
+
+// getDedupKey returns the key an incident is deduplicated with; numbers and uuids are removed from the error message of fingerprints
+func getDedupKey(keyType string, incident messages.Incident) string {
+	switch keyType {
+	case DedupKeyDefinition:
+		return "definition:" + incident.ProcessDefinitionId
+	case DedupKeyFingerprint:
+		hash := sha256.Sum256([]byte(fingerprintNormalization.ReplaceAllString(incident.ErrorMessage, "#")))
+		return "fingerprint:" + incident.ProcessDefinitionId + ":" + hex.EncodeToString(hash[:])
+	default:
+		//incident.ProcessInstanceId should be enough as key but existing tests would fail, so the incident.ProcessDefinitionId is added
+		return incident.ProcessDefinitionId + "+" + incident.ProcessInstanceId
+	}
+}
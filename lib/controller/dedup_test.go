@@ -0,0 +1,124 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/alicebob/miniredis/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetDedupKey(t *testing.T) {
+	incident := messages.Incident{ProcessDefinitionId: "d1", ProcessInstanceId: "i1", ErrorMessage: "timeout after 30s for device 0b1a7d3e-8f45-4c1b-9a2e-6f1c2d3e4f5a"}
+	sameError := messages.Incident{ProcessDefinitionId: "d1", ProcessInstanceId: "i2", ErrorMessage: "timeout after 10s for device 1c2b7d3e-8f45-4c1b-9a2e-6f1c2d3e4f5b"}
+	otherError := messages.Incident{ProcessDefinitionId: "d1", ProcessInstanceId: "i1", ErrorMessage: "unknown device"}
+	otherDefinition := messages.Incident{ProcessDefinitionId: "d2", ProcessInstanceId: "i1", ErrorMessage: incident.ErrorMessage}
+
+	cases := []struct {
+		keyType string
+		other   messages.Incident
+		equal   bool
+	}{
+		{keyType: "", other: incident, equal: true},
+		{keyType: DedupKeyInstance, other: sameError, equal: false},
+		{keyType: DedupKeyInstance, other: otherError, equal: true},
+		{keyType: DedupKeyInstance, other: otherDefinition, equal: false},
+		{keyType: DedupKeyDefinition, other: sameError, equal: true},
+		{keyType: DedupKeyDefinition, other: otherError, equal: true},
+		{keyType: DedupKeyDefinition, other: otherDefinition, equal: false},
+		{keyType: DedupKeyFingerprint, other: sameError, equal: true},
+		{keyType: DedupKeyFingerprint, other: otherError, equal: false},
+		{keyType: DedupKeyFingerprint, other: otherDefinition, equal: false},
+	}
+	for i, c := range cases {
+		if equal := getDedupKey(c.keyType, incident) == getDedupKey(c.keyType, c.other); equal != c.equal {
+			t.Errorf("%v: %v %#v %#v", i, c.keyType, getDedupKey(c.keyType, incident), getDedupKey(c.keyType, c.other))
+		}
+	}
+	if getDedupKey(DedupKeyInstance, incident) != "d1+i1" {
+		t.Error(getDedupKey(DedupKeyInstance, incident))
+	}
+}
+
+func TestNewDedupCacheConfig(t *testing.T) {
+	if _, err := newDedupCache(configuration.Config{IncidentDedupKey: "foo"}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+	if _, err := newDedupCache(configuration.Config{IncidentDedupMemcachedUrls: []string{"localhost:11211"}, IncidentDedupRedisUrl: "redis://localhost:6379"}); err == nil {
+		t.Error("expected error for memcached and redis")
+	}
+}
+
+type dedupTestMetrics struct {
+	sagaTestMetrics
+	mux        sync.Mutex
+	suppressed map[string]int
+	hits       int
+}
+
+func (this *dedupTestMetrics) NotifySuppressedDuplicateIncident(dedupKey string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.suppressed[dedupKey]++
+}
+
+func (this *dedupTestMetrics) NotifyDedupCacheLookup(hit bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if hit {
+		this.hits++
+	}
+}
+
+// two replicas share the redis l2 cache; the duplicate is suppressed by the replica that did not handle the first incident
+func TestSuppressDuplicateIncident(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	redis := miniredis.RunT(t)
+	config := configuration.Config{IncidentDedupWindow: "1m", IncidentDedupKey: DedupKeyDefinition, IncidentDedupRedisUrl: "redis://" + redis.Addr()}
+
+	camunda := &sagaTestCamunda{}
+	metrics := &dedupTestMetrics{suppressed: map[string]int{}}
+	replicas := []*Controller{}
+	for i := 0; i < 2; i++ {
+		ctrl, err := New(ctx, config, camunda, &sagaTestDb{sagas: map[string]messages.IncidentSaga{}}, metrics)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replicas = append(replicas, ctrl)
+	}
+
+	err := replicas[0].CreateIncident(ctx, messages.Incident{Id: "incident1", ProcessDefinitionId: "d1", ProcessInstanceId: "i1", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = replicas[1].CreateIncident(ctx, messages.Incident{Id: "incident2", ProcessDefinitionId: "d1", ProcessInstanceId: "i2", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = replicas[1].CreateIncident(ctx, messages.Incident{Id: "incident3", ProcessDefinitionId: "d2", ProcessInstanceId: "i3", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stops, _ := camunda.counts(); stops != 2 || metrics.suppressed[DedupKeyDefinition] != 1 || metrics.hits != 1 {
+		t.Fatal(stops, metrics.suppressed, metrics.hits)
+	}
+}
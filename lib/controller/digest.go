@@ -0,0 +1,161 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"slices"
+	"sync"
+	"time"
+)
+
+const DigestMaxListedErrors = 20
+const DigestMaxListedInstances = 50
+
+// NotificationDigest groups incident notifications per user and process-definition
+// and sends one digest at the end of the window.
+// the notifications are only kept in memory; the saga of the incident finishes its digest step after the digest has been sent,
+// so that notifications of a stopped worker are added again when the saga is resumed
+type NotificationDigest struct {
+	ctx         context.Context
+	running     *sync.WaitGroup
+	window      time.Duration
+	render      func(templateName string, userId string, data notification.TemplateData) notification.Message
+	send        func(msg notification.Message) error
+	onDelivered func(sagaIds []string) //called after the digest with the notifications of the sagas has been sent
+	mux         sync.Mutex
+	groups      map[string]*digestGroup
+	queued      map[string]bool  //saga ids
+	delivered   map[string]error //saga id -> send error
+}
+
+type digestGroup struct {
+	first        notification.Message
+	lastIncident messages.Incident
+	count        int
+	errors       []string
+	errorCounts  map[string]int
+	instances    []string
+	sagaIds      []string
+}
+
+// NewNotificationDigest creates a digest; groups that are due after ctx is done are dropped and added again by their resumed sagas.
+// the timers of the groups are tracked in running
+func NewNotificationDigest(ctx context.Context, running *sync.WaitGroup, window time.Duration, render func(templateName string, userId string, data notification.TemplateData) notification.Message, send func(msg notification.Message) error, onDelivered func(sagaIds []string)) *NotificationDigest {
+	return &NotificationDigest{
+		ctx:         ctx,
+		running:     running,
+		window:      window,
+		render:      render,
+		send:        send,
+		onDelivered: onDelivered,
+		groups:      map[string]*digestGroup{},
+		queued:      map[string]bool{},
+		delivered:   map[string]error{},
+	}
+}
+
+// Add registers the notification msg of the saga; the first notification of a group starts its window.
+// delivered is true (and err the send error) if the digest containing msg has been sent; repeated calls before that have no effect
+func (this *NotificationDigest) Add(sagaId string, incident messages.Incident, msg notification.Message) (delivered bool, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if err, delivered = this.delivered[sagaId]; delivered {
+		delete(this.delivered, sagaId)
+		return true, err
+	}
+	if this.queued[sagaId] {
+		return false, nil
+	}
+	this.queued[sagaId] = true
+	key := msg.UserId + "+" + incident.ProcessDefinitionId
+	group, ok := this.groups[key]
+	if !ok {
+		group = &digestGroup{first: msg, errorCounts: map[string]int{}}
+		this.groups[key] = group
+		this.running.Add(1)
+		go func() {
+			defer this.running.Done()
+			select {
+			case <-this.ctx.Done():
+			case <-time.After(this.window):
+			}
+			this.flush(key)
+		}()
+	}
+	group.count = group.count + 1
+	group.lastIncident = incident
+	if _, known := group.errorCounts[incident.ErrorMessage]; !known {
+		group.errors = append(group.errors, incident.ErrorMessage)
+	}
+	group.errorCounts[incident.ErrorMessage] = group.errorCounts[incident.ErrorMessage] + 1
+	if !slices.Contains(group.instances, incident.ProcessInstanceId) {
+		group.instances = append(group.instances, incident.ProcessInstanceId)
+	}
+	group.sagaIds = append(group.sagaIds, sagaId)
+	return false, nil
+}
+
+func (this *NotificationDigest) flush(key string) {
+	this.mux.Lock()
+	group, ok := this.groups[key]
+	delete(this.groups, key)
+	if ok && this.ctx.Err() != nil {
+		for _, id := range group.sagaIds {
+			delete(this.queued, id)
+		}
+	}
+	this.mux.Unlock()
+	if !ok || this.ctx.Err() != nil {
+		return
+	}
+	err := this.send(this.createDigestMessage(group))
+	this.mux.Lock()
+	for _, id := range group.sagaIds {
+		delete(this.queued, id)
+		this.delivered[id] = err
+	}
+	this.mux.Unlock()
+	this.onDelivered(group.sagaIds)
+}
+
+func (this *NotificationDigest) createDigestMessage(group *digestGroup) notification.Message {
+	if group.count == 1 {
+		return group.first
+	}
+	data := notification.TemplateData{
+		Incident:       group.lastIncident,
+		DeploymentName: group.lastIncident.DeploymentName,
+		Count:          group.count,
+		Window:         this.window.String(),
+		Instances:      group.instances,
+	}
+	for _, errMsg := range group.errors {
+		if len(data.Errors) == DigestMaxListedErrors {
+			data.MoreErrors = data.MoreErrors + 1
+			continue
+		}
+		data.Errors = append(data.Errors, notification.DigestError{Message: errMsg, Count: group.errorCounts[errMsg]})
+	}
+	if len(data.Instances) > DigestMaxListedInstances {
+		data.MoreInstances = len(data.Instances) - DigestMaxListedInstances
+		data.Instances = data.Instances[:DigestMaxListedInstances]
+	}
+	return this.render(notification.TemplateDigest, group.first.UserId, data)
+}
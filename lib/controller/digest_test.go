@@ -0,0 +1,102 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationDigest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := sync.Mutex{}
+	sent := []notification.Message{}
+	templates, err := notification.NewTemplates(notification.TemplatesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(templateName string, userId string, data notification.TemplateData) notification.Message {
+		msg, err := templates.Render(userId, templateName, data)
+		if err != nil {
+			t.Error(err)
+		}
+		return msg
+	}
+	delivered := []string{}
+	digest := NewNotificationDigest(ctx, &sync.WaitGroup{}, 500*time.Millisecond, render, func(msg notification.Message) error {
+		mux.Lock()
+		defer mux.Unlock()
+		sent = append(sent, msg)
+		return nil
+	}, func(sagaIds []string) {
+		mux.Lock()
+		defer mux.Unlock()
+		delivered = append(delivered, sagaIds...)
+	})
+
+	add := func(user string, definition string, instance string, errMsg string) bool {
+		result, _ := digest.Add(instance, messages.Incident{TenantId: user, ProcessDefinitionId: definition, ProcessInstanceId: instance, ErrorMessage: errMsg, DeploymentName: definition}, notification.Message{
+			UserId:  user,
+			Title:   "Process-Incident in " + definition,
+			Message: errMsg,
+			Topic:   notification.Topic,
+		})
+		return result
+	}
+	add("u1", "d1", "i1", "e1")
+	add("u1", "d1", "i2", "e1")
+	add("u1", "d1", "i3", "e2")
+	add("u2", "d1", "i4", "e1")
+	if add("u2", "d1", "i4", "e1") {
+		t.Fatal("repeated add before the digest has been sent")
+	}
+
+	time.Sleep(time.Second)
+
+	mux.Lock()
+	if len(sent) != 2 || len(delivered) != 4 {
+		t.Fatalf("%#v %#v", sent, delivered)
+	}
+	mux.Unlock()
+	//the sagas are continued and finish their digest step
+	if !add("u1", "d1", "i1", "e1") || add("u1", "d1", "i1", "e1") {
+		t.Fatal("saga i1 should be delivered once")
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	for _, msg := range sent {
+		switch msg.UserId {
+		case "u1":
+			if msg.Title != "3 Process-Incidents in d1" || !strings.Contains(msg.Message, "- (2x) e1") || !strings.Contains(msg.Message, "- (1x) e2") || !strings.Contains(msg.Message, "i1, i2, i3") {
+				t.Errorf("%#v", msg)
+			}
+		case "u2":
+			if msg.Title != "Process-Incident in d1" || msg.Message != "e1" {
+				t.Errorf("%#v", msg)
+			}
+		default:
+			t.Errorf("%#v", msg)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"slices"
+	"time"
+)
+
+// WorkerActor is the actor of status transitions made by the worker
+const WorkerActor = "process-incident-worker"
+
+var ErrIncidentNotFound = errors.New("incident not found")
+var ErrInvalidIncidentStatusTransition = errors.New("invalid incident status transition")
+
+// allowed transitions; resolved and auto_resolved are final
+var incidentStatusTransitions = map[string][]string{
+	messages.IncidentStatusOpen:         {messages.IncidentStatusAcknowledged, messages.IncidentStatusResolved, messages.IncidentStatusAutoResolved},
+	messages.IncidentStatusAcknowledged: {messages.IncidentStatusResolved, messages.IncidentStatusAutoResolved},
+}
+
+func ValidateIncidentStatusTransition(from string, to string) error {
+	if from == "" {
+		from = messages.IncidentStatusOpen
+	}
+	if !slices.Contains(incidentStatusTransitions[from], to) {
+		return errors.Join(ErrInvalidIncidentStatusTransition, errors.New(from+" -> "+to))
+	}
+	return nil
+}
+
+func newOpenIncidentStatus(incident *messages.Incident) {
+	incident.Status = messages.IncidentStatusOpen
+	incident.StatusHistory = []messages.IncidentStatusTransition{{To: messages.IncidentStatusOpen, Time: time.Now(), Actor: WorkerActor}}
+}
+
+// SetIncidentStatus validates and stores the transition of the incident to status
+func (this *Controller) SetIncidentStatus(incidentId string, status string, actor string, comment string) (transition messages.IncidentStatusTransition, err error) {
+	//the status may be changed between read and update; the update only succeeds if the status is unchanged
+	for attempt := 0; attempt < 3; attempt++ {
+		incident, exists, err := this.db.GetIncident(incidentId)
+		if err != nil {
+			return transition, err
+		}
+		if !exists {
+			return transition, ErrIncidentNotFound
+		}
+		from := incident.Status
+		if from == "" {
+			from = messages.IncidentStatusOpen
+		}
+		err = ValidateIncidentStatusTransition(from, status)
+		if err != nil {
+			return transition, err
+		}
+		transition = messages.IncidentStatusTransition{From: from, To: status, Time: time.Now(), Actor: actor, Comment: comment}
+		updated, err := this.db.SetIncidentStatus(incidentId, transition)
+		if err != nil {
+			return transition, err
+		}
+		if updated {
+			this.logger.Info("incident status changed", "snrgy-log-type", "process-incident", "incident-id", incidentId, "from", from, "to", status, "actor", actor, "user", incident.TenantId, "process-instance-id", incident.ProcessInstanceId)
+			return transition, nil
+		}
+	}
+	return transition, errors.New("incident status changed concurrently")
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"testing"
+)
+
+func TestValidateIncidentStatusTransition(t *testing.T) {
+	cases := []struct {
+		from  string
+		to    string
+		valid bool
+	}{
+		{from: "", to: messages.IncidentStatusAcknowledged, valid: true},
+		{from: messages.IncidentStatusOpen, to: messages.IncidentStatusResolved, valid: true},
+		{from: messages.IncidentStatusOpen, to: messages.IncidentStatusAutoResolved, valid: true},
+		{from: messages.IncidentStatusAcknowledged, to: messages.IncidentStatusResolved, valid: true},
+		{from: messages.IncidentStatusAcknowledged, to: messages.IncidentStatusAcknowledged, valid: false},
+		{from: messages.IncidentStatusResolved, to: messages.IncidentStatusAcknowledged, valid: false},
+		{from: messages.IncidentStatusAutoResolved, to: messages.IncidentStatusResolved, valid: false},
+		{from: messages.IncidentStatusOpen, to: messages.IncidentStatusOpen, valid: false},
+	}
+	for i, c := range cases {
+		err := ValidateIncidentStatusTransition(c.from, c.to)
+		if (err == nil) != c.valid {
+			t.Errorf("%v: %v -> %v: %v", i, c.from, c.to, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/source/util"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+)
+
+// newNotifiers creates the configured notification channels and returns the names of the default channels
+func newNotifiers(ctx context.Context, config configuration.Config) (notifiers map[string]notification.Notifier, defaultChannels []string, err error) {
+	channels := map[string]notification.ChannelConfig{}
+	if config.NotificationUrl != "" && config.NotificationUrl != "-" {
+		channels[notification.ChannelPlatform] = notification.ChannelConfig{Type: notification.ChannelTypePlatform, Url: config.NotificationUrl}
+		defaultChannels = append(defaultChannels, notification.ChannelPlatform)
+	}
+	if config.DeveloperNotificationUrl != "" && config.DeveloperNotificationUrl != "-" {
+		channels[notification.ChannelDeveloper] = notification.ChannelConfig{Type: notification.ChannelTypeDeveloper, Url: config.DeveloperNotificationUrl}
+		defaultChannels = append(defaultChannels, notification.ChannelDeveloper)
+	}
+	for name, channel := range config.NotificationChannels {
+		channels[name] = channel
+	}
+	if len(config.NotificationDefaultChannels) > 0 {
+		defaultChannels = config.NotificationDefaultChannels
+	}
+
+	notifiers = map[string]notification.Notifier{}
+	for name, channel := range channels {
+		if channel.Type == notification.ChannelTypeKafka {
+			if channel.Topic == "" {
+				return nil, nil, errors.New("missing topic for kafka notification channel " + name)
+			}
+			connection, err := util.NewKafkaConnection(config)
+			if err != nil {
+				return nil, nil, err
+			}
+			if channel.Url != "" {
+				connection = connection.WithBrokers(util.SplitBrokers(channel.Url)...)
+			}
+			err = util.InitTopic(connection, util.NewTopicSettings(config), channel.Topic)
+			if err != nil {
+				return nil, nil, err
+			}
+			notifiers[name] = notification.NewKafkaNotifier(ctx, connection.NewWriter(channel.Topic))
+			continue
+		}
+		notifiers[name], err = notification.NewNotifier(channel)
+		if err != nil {
+			return nil, nil, errors.New("invalid notification channel " + name + ": " + err.Error())
+		}
+	}
+
+	for _, channelNames := range append([][]string{defaultChannels}, getMapValues(config.NotificationTenantChannels)...) {
+		for _, name := range channelNames {
+			if _, ok := notifiers[name]; !ok {
+				return nil, nil, errors.New("unknown notification channel: " + name)
+			}
+		}
+	}
+	return notifiers, defaultChannels, nil
+}
+
+func getMapValues[K comparable, V any](m map[K]V) (result []V) {
+	for _, v := range m {
+		result = append(result, v)
+	}
+	return result
+}
+
+// getNotificationChannels returns the channels of the message, the channels of the tenant or the default channels
+func (this *Controller) getNotificationChannels(msg notification.Message) []string {
+	if len(msg.Channels) > 0 {
+		return msg.Channels
+	}
+	if channels, ok := this.config.NotificationTenantChannels[msg.UserId]; ok {
+		return channels
+	}
+	return this.defaultChannels
+}
+
+func (this *Controller) Notify(msg notification.Message) {
+	_ = this.notify(context.Background(), msg)
+}
+
+// notify sends msg to its channels and returns the joined errors of all channels; every channel is traced as child span of ctx
+func (this *Controller) notify(ctx context.Context, msg notification.Message) (err error) {
+	for _, name := range this.getNotificationChannels(msg) {
+		notifier, ok := this.notifiers[name]
+		if !ok {
+			this.logger.Error("unknown notification channel", "snrgy-log-type", "warning", "channel", name, "user", msg.UserId)
+			err = errors.Join(err, errors.New("unknown notification channel: "+name))
+			this.metrics.NotifyNotification(name, MetricResultUnknownChannel)
+			continue
+		}
+		if this.config.Debug {
+			log.Println("DEBUG: send notification", name, msg.UserId, msg.Title)
+		}
+		_, span := tracing.StartSpan(ctx, "notify "+name, trace.WithAttributes(attribute.String("channel", name), attribute.String("user", msg.UserId)))
+		channelErr := notifier.Notify(msg)
+		tracing.EndSpan(span, channelErr)
+		if channelErr != nil {
+			this.logger.ErrorContext(ctx, "unable to send notification", "snrgy-log-type", "error", "error", channelErr.Error(), "channel", name, "user", msg.UserId)
+			err = errors.Join(err, errors.New(name+": "+channelErr.Error()))
+			this.metrics.NotifyNotification(name, MetricResultError)
+		} else {
+			this.metrics.NotifyNotification(name, MetricResultSuccess)
+		}
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"time"
+)
+
+func (this *Controller) incidentHandledEventsEnabled() bool {
+	return this.config.KafkaIncidentHandledTopic != "" && this.config.KafkaIncidentHandledTopic != "-"
+}
+
+// publishIncidentHandled stores a messages.IncidentHandledEvent in the outbox; the outbox package publishes it to kafka
+func (this *Controller) publishIncidentHandled(incident messages.Incident, actions []messages.IncidentHandledAction, restartedProcessInstanceId string) {
+	if !this.incidentHandledEventsEnabled() {
+		return
+	}
+	if actions == nil {
+		actions = []messages.IncidentHandledAction{}
+	}
+	event := messages.IncidentHandledEvent{
+		Version:                    messages.IncidentHandledEventVersion,
+		Incident:                   incident,
+		Actions:                    actions,
+		RestartedProcessInstanceId: restartedProcessInstanceId,
+		Time:                       time.Now(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		this.logger.Error("unable to marshal incident handled event", "snrgy-log-type", "error", "error", err.Error(), "process-instance-id", incident.ProcessInstanceId)
+		return
+	}
+	err = this.db.SaveOutboxEvent(messages.OutboxEvent{
+		Id:        "incident-handled:" + getSagaId(incident), //repeated saves of the same event replace each other
+		Topic:     this.config.KafkaIncidentHandledTopic,
+		Key:       incident.ProcessInstanceId,
+		Payload:   string(payload),
+		CreatedAt: event.Time,
+	})
+	if err != nil {
+		this.logger.Error("unable to save incident handled event in outbox", "snrgy-log-type", "error", "error", err.Error(), "user", incident.TenantId, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"math"
+	"time"
+)
+
+const DefaultRestartDelayFactor = 2.0
+
+// MaxRestartStateUpdateAttempts limits the retries of a restart decision after concurrent updates of the restart state
+const MaxRestartStateUpdateAttempts = 5
+
+// MaxRestartStateDecisions limits the decisions kept in the restart state to recognize retried incidents
+const MaxRestartStateDecisions = 100
+
+type RestartDecision struct {
+	Restart   bool
+	Delay     time.Duration
+	Exhausted bool   //true if the budget of the window is used up with this incident; later incidents of the window return false
+	Action    string //OnExhausted action of the policy; only set if the budget is used up
+}
+
+func ValidateRestartPolicy(policy *messages.RestartPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxRestarts < 0 {
+		return errors.New("restart_policy.max_restarts may not be negative")
+	}
+	if policy.DelayFactor < 0 {
+		return errors.New("restart_policy.delay_factor may not be negative")
+	}
+	for _, d := range []string{policy.Window, policy.InitialDelay, policy.MaxDelay} {
+		if _, err := parseOptionalDuration(d); err != nil {
+			return err
+		}
+	}
+	switch policy.OnExhausted {
+	case "", messages.OnRestartBudgetExhaustedStop, messages.OnRestartBudgetExhaustedNotify, messages.OnRestartBudgetExhaustedDisable:
+	default:
+		return errors.New("unknown restart_policy.on_exhausted value: " + policy.OnExhausted)
+	}
+	return nil
+}
+
+// DecideRestart checks the restart budget of the policy and returns the decision together with the updated state.
+// a nil policy allows unlimited restarts without delay.
+func DecideRestart(policy *messages.RestartPolicy, state messages.RestartState, now time.Time) (decision RestartDecision, newState messages.RestartState, err error) {
+	newState = state
+	if state.Disabled {
+		return decision, newState, nil
+	}
+	if policy == nil {
+		decision.Restart = true
+		return decision, newState, nil
+	}
+	window, err := parseOptionalDuration(policy.Window)
+	if err != nil {
+		return decision, state, err
+	}
+	initialDelay, err := parseOptionalDuration(policy.InitialDelay)
+	if err != nil {
+		return decision, state, err
+	}
+	maxDelay, err := parseOptionalDuration(policy.MaxDelay)
+	if err != nil {
+		return decision, state, err
+	}
+
+	if newState.WindowStart.IsZero() || (window > 0 && now.Sub(newState.WindowStart) >= window) {
+		newState.WindowStart = now
+		newState.Count = 0
+		newState.Exhausted = false
+	}
+
+	if policy.MaxRestarts > 0 && newState.Count >= policy.MaxRestarts {
+		decision.Exhausted = !newState.Exhausted
+		newState.Exhausted = true
+		decision.Action = policy.OnExhausted
+		if decision.Action == "" {
+			decision.Action = messages.OnRestartBudgetExhaustedStop
+		}
+		if decision.Action == messages.OnRestartBudgetExhaustedDisable {
+			newState.Disabled = true
+			newState.DisabledAt = now
+		}
+		return decision, newState, nil
+	}
+
+	factor := policy.DelayFactor
+	if factor == 0 {
+		factor = DefaultRestartDelayFactor
+	}
+	delay := float64(initialDelay) * math.Pow(factor, float64(newState.Count))
+	if maxDelay > 0 && delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	if delay > math.MaxInt64 {
+		delay = math.MaxInt64
+	}
+
+	decision.Restart = true
+	decision.Delay = time.Duration(delay)
+	newState.Count = newState.Count + 1
+	newState.LastRestart = now
+	return decision, newState, nil
+}
+
+func parseOptionalDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecideRestart(t *testing.T) {
+	policy := &messages.RestartPolicy{
+		MaxRestarts:  3,
+		Window:       "1h",
+		InitialDelay: "1s",
+		MaxDelay:     "3s",
+		OnExhausted:  messages.OnRestartBudgetExhaustedDisable,
+	}
+	if err := ValidateRestartPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	state := messages.RestartState{}
+	expectedDelays := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	for i, expectedDelay := range expectedDelays {
+		decision, newState, err := DecideRestart(policy, state, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decision.Restart || decision.Exhausted || decision.Delay != expectedDelay {
+			t.Fatalf("%v: %#v", i, decision)
+		}
+		state = newState
+	}
+
+	decision, state, err := DecideRestart(policy, state, now.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Restart || !decision.Exhausted || decision.Action != messages.OnRestartBudgetExhaustedDisable || !state.Disabled {
+		t.Fatalf("%#v %#v", decision, state)
+	}
+
+	decision, _, err = DecideRestart(policy, state, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Restart {
+		t.Fatal("disabled handler should not restart")
+	}
+
+	//the budget is only reported as exhausted by the first incident that exceeds it
+	policy.OnExhausted = messages.OnRestartBudgetExhaustedNotify
+	state = messages.RestartState{WindowStart: now, Count: 3}
+	decision, state, err = DecideRestart(policy, state, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Restart || !decision.Exhausted || decision.Action != messages.OnRestartBudgetExhaustedNotify || state.Disabled {
+		t.Fatalf("%#v %#v", decision, state)
+	}
+	decision, state, err = DecideRestart(policy, state, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Restart || decision.Exhausted {
+		t.Fatalf("%#v %#v", decision, state)
+	}
+
+	policy.OnExhausted = messages.OnRestartBudgetExhaustedStop
+	state = messages.RestartState{WindowStart: now, Count: 3, Exhausted: true}
+	decision, state, err = DecideRestart(policy, state, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Restart || decision.Delay != time.Second || state.Count != 1 {
+		t.Fatalf("window should have been reset: %#v %#v", decision, state)
+	}
+}
+
+type restartStateTestDb struct {
+	interfaces.Database
+	handler        messages.OnIncident
+	concurrentUses int
+}
+
+func (this *restartStateTestDb) GetOnIncident(definitionId string) (messages.OnIncident, bool, error) {
+	return this.handler, true, nil
+}
+
+func (this *restartStateTestDb) UpdateOnIncidentRestartState(definitionId string, version int64, state messages.RestartState) (bool, error) {
+	if this.concurrentUses > 0 {
+		//another replica uses the budget first
+		this.concurrentUses--
+		newState := *this.handler.RestartState
+		newState.Count++
+		newState.Version++
+		this.handler.RestartState = &newState
+	}
+	if this.handler.RestartState.Version != version {
+		return false, nil
+	}
+	state.Version = version + 1
+	this.handler.RestartState = &state
+	return true, nil
+}
+
+func TestCheckRestartBudgetConcurrentUpdate(t *testing.T) {
+	handler := messages.OnIncident{
+		ProcessDefinitionId: "d1",
+		Restart:             true,
+		RestartPolicy:       &messages.RestartPolicy{MaxRestarts: 2},
+		RestartState:        &messages.RestartState{WindowStart: time.Now(), Count: 1, Version: 1},
+	}
+	db := &restartStateTestDb{handler: handler, concurrentUses: 1}
+	ctrl := &Controller{db: db}
+	decision, err := ctrl.checkRestartBudget(handler, "incident1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the last restart of the budget has been used by the other replica
+	if decision.Restart || !decision.Exhausted || db.handler.RestartState.Count != 2 || db.handler.RestartState.Version != 3 {
+		t.Fatalf("%#v %#v", decision, db.handler.RestartState)
+	}
+}
+
+func TestCheckRestartBudgetRetriedIncident(t *testing.T) {
+	handler := messages.OnIncident{
+		ProcessDefinitionId: "d1",
+		Restart:             true,
+		RestartPolicy:       &messages.RestartPolicy{MaxRestarts: 1, InitialDelay: "1s"},
+		RestartState:        &messages.RestartState{},
+	}
+	db := &restartStateTestDb{handler: handler}
+	ctrl := &Controller{db: db}
+	decision, err := ctrl.checkRestartBudget(db.handler, "incident1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Restart || decision.Delay != time.Second {
+		t.Fatalf("%#v", decision)
+	}
+	//retry of the incident, e.g. after a crash before the saga has been saved
+	retried, err := ctrl.checkRestartBudget(db.handler, "incident1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried != decision || db.handler.RestartState.Count != 1 || db.handler.RestartState.Version != 1 {
+		t.Fatalf("%#v %#v", retried, db.handler.RestartState)
+	}
+	decision, err = ctrl.checkRestartBudget(db.handler, "incident2", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Restart || !decision.Exhausted || len(db.handler.RestartState.Decisions) != 2 {
+		t.Fatalf("%#v %#v", decision, db.handler.RestartState)
+	}
+}
+
+func TestValidateRestartPolicy(t *testing.T) {
+	if err := ValidateRestartPolicy(&messages.RestartPolicy{Window: "foo"}); err == nil {
+		t.Error("expected error for invalid window")
+	}
+	if err := ValidateRestartPolicy(&messages.RestartPolicy{OnExhausted: "foo"}); err == nil {
+		t.Error("expected error for invalid on_exhausted")
+	}
+	if err := ValidateRestartPolicy(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+type restartVariablesTestCamunda struct {
+	interfaces.Camunda
+	variables map[string]interface{}
+	err       error
+}
+
+func (this *restartVariablesTestCamunda) GetProcessInstanceVariables(ctx context.Context, id string, tenantId string) (map[string]interface{}, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+	return this.variables, nil
+}
+
+func TestGetRestartVariables(t *testing.T) {
+	camunda := &restartVariablesTestCamunda{variables: map[string]interface{}{"a": 1.0, "b": "instance"}}
+	ctrl := &Controller{camunda: camunda, logger: slog.Default()}
+	incident := messages.Incident{ProcessDefinitionId: "d1", ProcessInstanceId: "i1", TenantId: "user"}
+	handling := messages.OnIncident{ProcessDefinitionId: "d1", RestartVariables: map[string]interface{}{"b": "handler", "c": true}}
+
+	//the variables of the handler overwrite the variables of the instance
+	variables, err := ctrl.getRestartVariables(context.Background(), incident, handling)
+	if err != nil || !reflect.DeepEqual(variables, map[string]interface{}{"a": 1.0, "b": "handler", "c": true}) {
+		t.Fatal(variables, err)
+	}
+
+	//the instance is unknown (e.g. finished): only the variables of the handler
+	camunda.variables = map[string]interface{}{}
+	variables, err = ctrl.getRestartVariables(context.Background(), incident, handling)
+	if err != nil || !reflect.DeepEqual(variables, map[string]interface{}{"b": "handler", "c": true}) {
+		t.Fatal(variables, err)
+	}
+
+	camunda.err = errors.New("test error")
+	variables, err = ctrl.getRestartVariables(context.Background(), incident, handling)
+	if err != nil || !reflect.DeepEqual(variables, map[string]interface{}{"b": "handler", "c": true}) {
+		t.Fatal(variables, err)
+	}
+
+	//retried when the shard is available again
+	camunda.err = interfaces.ShardUnavailableError{Shard: "shard", RetryAt: time.Now()}
+	_, err = ctrl.getRestartVariables(context.Background(), incident, handling)
+	if !errors.Is(err, interfaces.ErrShardUnavailable) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"regexp"
+	"sync"
+)
+
+// compiledPatterns caches the compiled regular expressions of the rules by their pattern;
+// ValidateIncidentRules compiles the global rules and new handler rules, stored handler rules are compiled on first use
+var compiledPatterns = sync.Map{}
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if cached, ok := compiledPatterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	result, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(expr, result)
+	return result, nil
+}
+
+func ValidateIncidentRules(rules []messages.IncidentRule) error {
+	for _, rule := range rules {
+		switch rule.Action {
+		case messages.IncidentActionStop, messages.IncidentActionRestart, messages.IncidentActionIgnore, messages.IncidentActionNotifyOnly, messages.IncidentActionEscalate:
+		default:
+			return errors.New("unknown incident rule action: " + rule.Action)
+		}
+		for _, expr := range []string{rule.ErrorMessage, rule.WorkerId} {
+			if _, err := compilePattern(expr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MatchIncidentRule returns the first rule matching the incident
+func MatchIncidentRule(rules []messages.IncidentRule, incident messages.Incident) (rule messages.IncidentRule, found bool, err error) {
+	for _, rule = range rules {
+		found, err = matchIncidentRule(rule, incident)
+		if err != nil || found {
+			return rule, found, err
+		}
+	}
+	return messages.IncidentRule{}, false, nil
+}
+
+func matchIncidentRule(rule messages.IncidentRule, incident messages.Incident) (bool, error) {
+	if rule.ActivityId != "" && rule.ActivityId != incident.ActivityId {
+		return false, nil
+	}
+	if rule.TenantId != "" && rule.TenantId != incident.TenantId {
+		return false, nil
+	}
+	if rule.WorkerId != "" {
+		match, err := matchPattern(rule.WorkerId, incident.WorkerId)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	if rule.ErrorMessage != "" {
+		match, err := matchPattern(rule.ErrorMessage, incident.ErrorMessage)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchPattern(expr string, value string) (bool, error) {
+	pattern, err := compilePattern(expr)
+	if err != nil {
+		return false, err
+	}
+	return pattern.MatchString(value), nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"testing"
+)
+
+func TestMatchIncidentRule(t *testing.T) {
+	rules := []messages.IncidentRule{
+		{ErrorMessage: "(?i)timeout", TenantId: "user1", Action: messages.IncidentActionRestart},
+		{WorkerId: "^mgw-", Action: messages.IncidentActionIgnore},
+		{ErrorMessage: "timeout", Action: messages.IncidentActionNotifyOnly},
+		{ActivityId: "Task_1", Action: messages.IncidentActionEscalate},
+	}
+	err := ValidateIncidentRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := compiledPatterns.Load("(?i)timeout"); !ok {
+		t.Fatal("pattern should be compiled by ValidateIncidentRules")
+	}
+
+	cases := []struct {
+		incident messages.Incident
+		found    bool
+		action   string
+	}{
+		{incident: messages.Incident{ErrorMessage: "Timeout while waiting", TenantId: "user1"}, found: true, action: messages.IncidentActionRestart},
+		{incident: messages.Incident{ErrorMessage: "Timeout while waiting", TenantId: "user2"}, found: false},
+		{incident: messages.Incident{ErrorMessage: "timeout", TenantId: "user2", WorkerId: "mgw-1"}, found: true, action: messages.IncidentActionIgnore},
+		{incident: messages.Incident{ErrorMessage: "timeout", TenantId: "user2", WorkerId: "worker"}, found: true, action: messages.IncidentActionNotifyOnly},
+		{incident: messages.Incident{ErrorMessage: "foo", ActivityId: "Task_1"}, found: true, action: messages.IncidentActionEscalate},
+	}
+	for i, c := range cases {
+		rule, found, err := MatchIncidentRule(rules, c.incident)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if found != c.found || rule.Action != c.action {
+			t.Errorf("%v: found=%v action=%v", i, found, rule.Action)
+		}
+	}
+
+	if err = ValidateIncidentRules([]messages.IncidentRule{{ErrorMessage: "(", Action: messages.IncidentActionStop}}); err == nil {
+		t.Error("expected regex error")
+	}
+	if err = ValidateIncidentRules([]messages.IncidentRule{{Action: "foo"}}); err == nil {
+		t.Error("expected action error")
+	}
+}
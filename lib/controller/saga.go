@@ -0,0 +1,401 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SagaLease is the time a replica may run a saga step before other replicas may take over the saga
+const SagaLease = 2 * time.Minute
+
+// getSagaId returns the id of the incident; incidents without id are identified by their instance and time,
+// which are the same for redelivered messages
+func getSagaId(incident messages.Incident) string {
+	if incident.Id != "" {
+		return incident.Id
+	}
+	return incident.ProcessInstanceId + "+" + incident.Time.UTC().Format(time.RFC3339Nano)
+}
+
+func toSagaNotification(msg notification.Message) *messages.SagaNotification {
+	return &messages.SagaNotification{UserId: msg.UserId, Title: msg.Title, Message: msg.Message, Topic: msg.Topic, Channels: msg.Channels}
+}
+
+func fromSagaNotification(msg *messages.SagaNotification) notification.Message {
+	return notification.Message{UserId: msg.UserId, Title: msg.Title, Message: msg.Message, Topic: msg.Topic, Channels: msg.Channels}
+}
+
+// errSagaStepPending is returned by runSagaStep for digest steps until the digest has been sent;
+// the saga is finished after all steps are done
+var errSagaStepPending = errors.New("saga step pending")
+
+func newDoneSagaStep(action string, target string, err error) messages.SagaStep {
+	step := messages.SagaStep{Action: action, Target: target, Done: true, DoneAt: time.Now()}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	return step
+}
+
+// ResumeIncidentSagas continues the unfinished sagas that are not run by a replica (e.g. after a crash) now and then every SagaLease until ctx is done.
+// running is Done after the periodic check has stopped and the resumed sagas have returned
+func (this *Controller) ResumeIncidentSagas(ctx context.Context, running *sync.WaitGroup) error {
+	err := this.resumeIncidentSagas(running)
+	if err != nil {
+		return err
+	}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		ticker := time.NewTicker(SagaLease)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := this.resumeIncidentSagas(running)
+				if err != nil {
+					this.logger.Error("unable to list unfinished incident sagas", "snrgy-log-type", "error", "error", err.Error())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (this *Controller) resumeIncidentSagas(running *sync.WaitGroup) error {
+	sagas, err := this.db.ListUnfinishedIncidentSagas()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, saga := range sagas {
+		if saga.Owner != "" && saga.LeaseUntil.After(now) {
+			//run by a replica or waiting for a timer of its owner
+			continue
+		}
+		running.Add(1)
+		go func(id string) {
+			defer running.Done()
+			err := this.continueSaga(context.Background(), id)
+			if err != nil {
+				this.logger.Error("unable to resume incident saga", "snrgy-log-type", "error", "error", err.Error(), "saga", id)
+			}
+		}(saga.Id)
+	}
+	return nil
+}
+
+// continueSaga claims the saga and runs its unfinished steps in order; sagas run by another replica are skipped.
+// a step that fails with an error stops the saga; it is retried when the incident is redelivered or the lease of the saga has expired.
+// a step that fails because the camunda shard is unavailable is put on hold until the circuit breaker of the shard allows calls again.
+// the state is saved after every step, only a crash between a step and the save repeats the step.
+// every save renews the lease; while the saga waits, the lease lasts until the end of the wait
+func (this *Controller) continueSaga(ctx context.Context, id string) error {
+	lockKey := "saga:" + id
+	this.lock(MetricLockSaga, lockKey)
+	defer this.mux.Unlock(lockKey)
+	saga, claimed, err := this.db.ClaimIncidentSaga(id, this.owner, time.Now().Add(SagaLease))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		//finished, unknown or run by another replica
+		return nil
+	}
+	var pendingUntil time.Time
+	for i := 0; i < len(saga.Steps); i++ {
+		if saga.Steps[i].Done {
+			continue
+		}
+		if wait := time.Until(saga.Steps[i].NotBefore); wait > 0 {
+			err = this.saveSaga(&saga, later(saga.Steps[i].NotBefore, pendingUntil))
+			if err != nil {
+				return err
+			}
+			this.continueSagaAfter(ctx, id, wait)
+			return nil
+		}
+		err = this.runSagaStep(ctx, &saga, i)
+		if errors.Is(err, errSagaStepPending) {
+			//the saga is continued after the digest has been sent; the following steps do not depend on it
+			pendingUntil = time.Now().Add(this.digest.window)
+			continue
+		}
+		var unavailable interfaces.ShardUnavailableError
+		if errors.As(err, &unavailable) {
+			this.logger.WarnContext(ctx, "camunda shard unavailable -> incident on hold", "snrgy-log-type", "warning", "error", err.Error(), "saga", id, "retry-at", unavailable.RetryAt.String())
+			saga.Steps[i].NotBefore = unavailable.RetryAt
+			saga.Steps[i].Error = ""
+			err = this.saveSaga(&saga, later(unavailable.RetryAt, pendingUntil))
+			if err != nil {
+				return err
+			}
+			this.continueSagaAfter(ctx, id, time.Until(unavailable.RetryAt))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		saga.Steps[i].Done = true
+		saga.Steps[i].DoneAt = time.Now()
+		if !slices.ContainsFunc(saga.Steps, func(step messages.SagaStep) bool { return !step.Done }) {
+			saga.Finished = true
+			saga.FinishedAt = &saga.Steps[i].DoneAt
+		}
+		err = this.saveSaga(&saga, later(time.Now(), pendingUntil))
+		if err != nil {
+			return err
+		}
+	}
+	if !pendingUntil.IsZero() {
+		return this.saveSaga(&saga, pendingUntil)
+	}
+	return nil
+}
+
+// saveSaga saves the saga with a lease that ends SagaLease after until
+func (this *Controller) saveSaga(saga *messages.IncidentSaga, until time.Time) error {
+	saga.Owner = this.owner
+	saga.LeaseUntil = until.Add(SagaLease)
+	return this.db.SaveIncidentSaga(*saga)
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// continueSagas continues the sagas in the background, e.g. after their digest has been sent
+func (this *Controller) continueSagas(ids []string) {
+	for _, id := range ids {
+		this.background.Add(1)
+		go func(id string) {
+			defer this.background.Done()
+			err := this.continueSaga(context.Background(), id)
+			if err != nil {
+				this.logger.Error("unable to continue incident saga", "snrgy-log-type", "error", "error", err.Error(), "saga", id)
+			}
+		}(id)
+	}
+}
+
+// continueSagaAfter continues the saga in a new trace that is linked to the span of ctx
+func (this *Controller) continueSagaAfter(ctx context.Context, id string, wait time.Duration) {
+	link := trace.LinkFromContext(ctx)
+	this.background.Add(1)
+	go func() {
+		defer this.background.Done()
+		select {
+		case <-this.ctx.Done():
+			//continued by ResumeIncidentSagas of a replica after the lease has expired
+		case <-time.After(wait):
+			sagaCtx, span := tracing.StartSpan(context.Background(), "continue incident saga", trace.WithLinks(link), trace.WithAttributes(attribute.String("saga", id)))
+			err := this.continueSaga(sagaCtx, id)
+			tracing.EndSpan(span, err)
+			if err != nil {
+				this.logger.ErrorContext(sagaCtx, "unable to continue incident saga", "snrgy-log-type", "error", "error", err.Error(), "saga", id)
+			}
+		}
+	}()
+}
+
+// runSagaStep executes the step; errors of steps that may not be skipped are returned, other errors are stored in the step.
+// interfaces.ErrShardUnavailable is always returned, before the saga is changed.
+// steps may append follow-up steps (e.g. the notification about a failed restart)
+func (this *Controller) runSagaStep(ctx context.Context, saga *messages.IncidentSaga, index int) error {
+	incident := saga.Incident
+	step := saga.Steps[index]
+	setError := func(err error) {
+		if err != nil {
+			saga.Steps[index].Error = err.Error()
+		}
+	}
+	switch step.Action {
+	case messages.HandledActionNotify:
+		setError(this.notify(ctx, fromSagaNotification(step.Notification)))
+	case messages.HandledActionDigest:
+		if this.digest != nil {
+			delivered, err := this.digest.Add(saga.Id, incident, fromSagaNotification(step.Notification))
+			if !delivered {
+				return errSagaStepPending
+			}
+			setError(err)
+		} else {
+			setError(this.notify(ctx, fromSagaNotification(step.Notification)))
+		}
+	case messages.HandledActionStop:
+		err := this.camunda.StopProcessInstance(ctx, incident.ProcessInstanceId, incident.TenantId)
+		this.notifyCamundaAction(messages.HandledActionStop, err)
+		return err
+	case messages.SagaStepSave:
+		return this.saveIncident(ctx, incident)
+	case messages.HandledActionSuspend:
+		//retried until the definition is suspended; the following notification announces the suspension
+		return this.camunda.SetProcessDefinitionSuspended(ctx, step.Target, incident.TenantId, true)
+	case messages.HandledActionRestart:
+		instanceId, err := this.camunda.StartProcess(ctx, incident.ProcessDefinitionId, incident.TenantId, step.Variables)
+		this.notifyCamundaAction(messages.HandledActionRestart, err)
+		if errors.Is(err, interfaces.ErrShardUnavailable) {
+			return err
+		}
+		saga.Steps[index].Target = instanceId
+		setError(err)
+		if err == nil {
+			this.insertSagaStep(saga, index+1, messages.SagaStep{Action: messages.SagaStepAutoResolve, Target: instanceId})
+		} else {
+			this.logger.ErrorContext(ctx, "unable to restart process", "snrgy-log-type", "process-incident", "error", err.Error(), "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+			this.addErrorNotificationStep(saga, index+1, notification.TemplateRestartError, notification.TemplateData{
+				Incident:       incident,
+				DeploymentName: incident.DeploymentName,
+				Actions:        []string{"restart"},
+				Restart:        true,
+				Error:          err.Error(),
+			})
+		}
+	case messages.HandledActionResume:
+		return this.resumeProcess(ctx, saga, index)
+	case messages.SagaStepSetMode:
+		err := this.db.SetIncidentHandlingMode(incident.Id, step.Target)
+		if err != nil {
+			return err
+		}
+		saga.Incident.HandlingMode = step.Target
+	case messages.SagaStepAutoResolve:
+		comment := "process-instance resumed"
+		if step.Target != "" {
+			comment = "process restarted as " + step.Target
+		}
+		transition, err := this.SetIncidentStatus(incident.Id, messages.IncidentStatusAutoResolved, WorkerActor, comment)
+		if errors.Is(err, ErrIncidentNotFound) || errors.Is(err, ErrInvalidIncidentStatusTransition) {
+			//e.g. resolved by the user in the meantime
+			setError(err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		saga.Incident.Status = transition.To
+		saga.Incident.StatusHistory = append(saga.Incident.StatusHistory, transition)
+		saga.Incident.ResolvedAt = &transition.Time
+		saga.Incident.ResolvedBy = transition.Actor
+	case messages.SagaStepPublish:
+		actions := []messages.IncidentHandledAction{}
+		restartedProcessInstanceId := ""
+		for _, s := range saga.Steps {
+			if s.Action == messages.SagaStepSave || s.Action == messages.SagaStepPublish || s.Action == messages.SagaStepAutoResolve || s.Action == messages.SagaStepSetMode {
+				continue
+			}
+			actions = append(actions, messages.IncidentHandledAction{Action: s.Action, Target: s.Target, Error: s.Error})
+			if s.Action == messages.HandledActionRestart && s.Error == "" {
+				restartedProcessInstanceId = s.Target
+			}
+		}
+		this.publishIncidentHandled(incident, actions, restartedProcessInstanceId)
+	default:
+		return errors.New("unknown incident saga step: " + step.Action)
+	}
+	return nil
+}
+
+// resumeProcess restarts the failed activity inside the existing process instance.
+// if that is not possible, the instance is stopped and a restart step is added like in the default mode.
+// only interfaces.ErrShardUnavailable is returned
+func (this *Controller) resumeProcess(ctx context.Context, saga *messages.IncidentSaga, index int) error {
+	incident := saga.Incident
+	err := this.camunda.ResumeProcessInstance(ctx, incident.ProcessInstanceId, incident.TenantId, incident.ExternalTaskId, saga.Handling.ResumeActivityId)
+	this.notifyCamundaAction(messages.HandledActionResume, err)
+	if errors.Is(err, interfaces.ErrShardUnavailable) {
+		return err
+	}
+	if err == nil {
+		//the mode is only stored after the resume, a fallback is handled in the default mode
+		this.insertSagaStep(saga, index+1, messages.SagaStep{Action: messages.SagaStepSetMode, Target: messages.OnIncidentModeResume})
+		this.insertSagaStep(saga, index+2, messages.SagaStep{Action: messages.SagaStepAutoResolve})
+		return nil
+	}
+	this.logger.ErrorContext(ctx, "unable to resume process -> fallback to restart", "snrgy-log-type", "process-incident", "error", err.Error(), "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+	resumeErr := err
+	variables, err := this.getRestartVariables(ctx, incident, saga.Handling)
+	if err != nil {
+		return err
+	}
+	err = this.camunda.StopProcessInstance(ctx, incident.ProcessInstanceId, incident.TenantId)
+	this.notifyCamundaAction(messages.HandledActionStop, err)
+	if errors.Is(err, interfaces.ErrShardUnavailable) {
+		return err
+	}
+	saga.Steps[index].Error = resumeErr.Error()
+	if err != nil {
+		this.logger.ErrorContext(ctx, "unable to stop process after failed resume", "snrgy-log-type", "process-incident", "error", err.Error(), "user", incident.TenantId, "deployment-name", incident.DeploymentName, "process-definition-id", incident.ProcessDefinitionId, "process-instance-id", incident.ProcessInstanceId)
+		this.insertSagaStep(saga, index+1, newDoneSagaStep(messages.HandledActionStop, "", err))
+		this.addErrorNotificationStep(saga, index+2, notification.TemplateResumeError, notification.TemplateData{
+			Incident:       incident,
+			DeploymentName: incident.DeploymentName,
+			Actions:        []string{"resume", "stop"},
+			Resume:         true,
+			Error:          err.Error(),
+		})
+		return nil
+	}
+	this.insertSagaStep(saga, index+1, newDoneSagaStep(messages.HandledActionStop, "", nil))
+	this.insertSagaStep(saga, index+2, messages.SagaStep{Action: messages.HandledActionRestart, Variables: variables})
+	return nil
+}
+
+func (this *Controller) notifyCamundaAction(action string, err error) {
+	switch {
+	case err == nil:
+		this.metrics.NotifyCamundaAction(action, MetricResultSuccess)
+	case errors.Is(err, interfaces.ErrShardUnavailable):
+		this.metrics.NotifyCamundaAction(action, MetricResultUnavailable)
+	default:
+		this.metrics.NotifyCamundaAction(action, MetricResultError)
+	}
+}
+
+// addErrorNotificationStep inserts a notification for the tenant at index
+func (this *Controller) addErrorNotificationStep(saga *messages.IncidentSaga, index int, templateName string, data notification.TemplateData) {
+	if saga.Incident.TenantId == "" {
+		return
+	}
+	msg := this.createNotification(templateName, saga.Incident.TenantId, data)
+	msg.Channels = saga.Handling.NotificationChannels
+	this.insertSagaStep(saga, index, messages.SagaStep{Action: messages.HandledActionNotify, Target: msg.UserId, Notification: toSagaNotification(msg)})
+}
+
+func (this *Controller) insertSagaStep(saga *messages.IncidentSaga, index int, step messages.SagaStep) {
+	if index >= len(saga.Steps) {
+		saga.Steps = append(saga.Steps, step)
+		return
+	}
+	saga.Steps = append(saga.Steps[:index], append([]messages.SagaStep{step}, saga.Steps[index:]...)...)
+}
@@ -0,0 +1,429 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sagaTestDb struct {
+	interfaces.Database
+	mux              sync.Mutex
+	handler          messages.OnIncident
+	sagas            map[string]messages.IncidentSaga
+	incidents        []messages.Incident
+	saveIncidentErrs int
+}
+
+func (this *sagaTestDb) GetOnIncident(definitionId string) (messages.OnIncident, bool, error) {
+	return this.handler, this.handler.ProcessDefinitionId == definitionId, nil
+}
+
+func (this *sagaTestDb) SaveIncident(incident messages.Incident) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.saveIncidentErrs > 0 {
+		this.saveIncidentErrs--
+		return errors.New("mongo unavailable")
+	}
+	this.incidents = append(this.incidents, incident)
+	return nil
+}
+
+func (this *sagaTestDb) GetIncident(id string) (messages.Incident, bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, incident := range this.incidents {
+		if incident.Id == id {
+			return incident, true, nil
+		}
+	}
+	return messages.Incident{}, false, nil
+}
+
+func (this *sagaTestDb) SetIncidentStatus(id string, transition messages.IncidentStatusTransition) (bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for i, incident := range this.incidents {
+		if incident.Id == id && (incident.Status == transition.From || (incident.Status == "" && transition.From == messages.IncidentStatusOpen)) {
+			this.incidents[i].Status = transition.To
+			this.incidents[i].StatusHistory = append(this.incidents[i].StatusHistory, transition)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (this *sagaTestDb) SetIncidentHandlingMode(id string, mode string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for i, incident := range this.incidents {
+		if incident.Id == id {
+			this.incidents[i].HandlingMode = mode
+		}
+	}
+	return nil
+}
+
+func (this *sagaTestDb) SaveIncidentSaga(saga messages.IncidentSaga) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	saga.Steps = append([]messages.SagaStep{}, saga.Steps...)
+	this.sagas[saga.Id] = saga
+	return nil
+}
+
+func (this *sagaTestDb) GetIncidentSaga(id string) (messages.IncidentSaga, bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	saga, ok := this.sagas[id]
+	saga.Steps = append([]messages.SagaStep{}, saga.Steps...)
+	return saga, ok, nil
+}
+
+func (this *sagaTestDb) ClaimIncidentSaga(id string, owner string, leaseUntil time.Time) (messages.IncidentSaga, bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	saga, ok := this.sagas[id]
+	if !ok || saga.Finished || (saga.Owner != "" && saga.Owner != owner && saga.LeaseUntil.After(time.Now())) {
+		return messages.IncidentSaga{}, false, nil
+	}
+	saga.Owner = owner
+	saga.LeaseUntil = leaseUntil
+	this.sagas[id] = saga
+	saga.Steps = append([]messages.SagaStep{}, saga.Steps...)
+	return saga, true, nil
+}
+
+func (this *sagaTestDb) ListUnfinishedIncidentSagas() (result []messages.IncidentSaga, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, saga := range this.sagas {
+		if !saga.Finished {
+			result = append(result, saga)
+		}
+	}
+	return result, nil
+}
+
+type sagaTestCamunda struct {
+	interfaces.Camunda
+	mux              sync.Mutex
+	stops            int
+	starts           int
+	resumes          int
+	resumeErr        error
+	unavailableUntil time.Time
+}
+
+func (this *sagaTestCamunda) GetProcessName(ctx context.Context, id string, tenantId string) (string, error) {
+	return "name", nil
+}
+
+func (this *sagaTestCamunda) GetProcessInstanceVariables(ctx context.Context, id string, tenantId string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (this *sagaTestCamunda) StopProcessInstance(ctx context.Context, id string, tenantId string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.stops++
+	return nil
+}
+
+func (this *sagaTestCamunda) ResumeProcessInstance(ctx context.Context, id string, tenantId string, externalTaskId string, targetActivityId string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.resumes++
+	return this.resumeErr
+}
+
+func (this *sagaTestCamunda) StartProcess(ctx context.Context, processDefinitionId string, userId string, variables map[string]interface{}) (string, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if time.Now().Before(this.unavailableUntil) {
+		return "", interfaces.ShardUnavailableError{Shard: "shard", RetryAt: this.unavailableUntil}
+	}
+	this.starts++
+	return "new-instance", nil
+}
+
+func (this *sagaTestCamunda) counts() (stops int, starts int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.stops, this.starts
+}
+
+type sagaTestMetrics struct{}
+
+func (this sagaTestMetrics) NotifyIncidentMessage()                                    {}
+func (this sagaTestMetrics) NotifySuppressedDuplicateIncident(dedupKey string)         {}
+func (this sagaTestMetrics) NotifyDedupCacheLookup(hit bool)                           {}
+func (this sagaTestMetrics) NotifyMessageParseError(version string)                    {}
+func (this sagaTestMetrics) ObserveGetOnIncident(duration time.Duration)               {}
+func (this sagaTestMetrics) NotifyCamundaAction(action string, result string)          {}
+func (this sagaTestMetrics) NotifyNotification(channel string, result string)          {}
+func (this sagaTestMetrics) ObserveTopicMutexWait(lock string, duration time.Duration) {}
+
+type sagaTestNotifier struct {
+	mux   sync.Mutex
+	count int
+}
+
+func (this *sagaTestNotifier) Notify(msg notification.Message) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.count++
+	return nil
+}
+
+func (this *sagaTestNotifier) sent() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.count
+}
+
+func TestIncidentSaga(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &sagaTestDb{
+		handler:          messages.OnIncident{ProcessDefinitionId: "d1", Restart: true, Notify: true},
+		sagas:            map[string]messages.IncidentSaga{},
+		saveIncidentErrs: 1,
+	}
+	camunda := &sagaTestCamunda{}
+	ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-"}, camunda, db, sagaTestMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &sagaTestNotifier{}
+	ctrl.notifiers = map[string]notification.Notifier{"test": notifier}
+	ctrl.defaultChannels = []string{"test"}
+
+	incident := messages.Incident{Id: "incident1", ProcessDefinitionId: "d1", ProcessInstanceId: "i1", TenantId: "user", ErrorMessage: "error", Time: time.Now()}
+
+	err = ctrl.CreateIncident(ctx, incident)
+	if err == nil {
+		t.Fatal("expected save error")
+	}
+	if stops, starts := camunda.counts(); stops != 1 || starts != 0 || notifier.count != 1 {
+		t.Fatal(stops, starts, notifier.count)
+	}
+
+	//redelivery continues at the save step
+	err = ctrl.CreateIncident(ctx, incident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stops, starts := camunda.counts(); stops != 1 || starts != 1 || notifier.count != 1 || len(db.incidents) != 1 {
+		t.Fatal(stops, starts, notifier.count, len(db.incidents))
+	}
+	saga, _, _ := db.GetIncidentSaga("incident1")
+	if !saga.Finished || saga.Steps[len(saga.Steps)-3].Target != "new-instance" || saga.Steps[len(saga.Steps)-2].Action != messages.SagaStepAutoResolve {
+		t.Fatalf("%#v", saga)
+	}
+	if db.incidents[0].Status != messages.IncidentStatusAutoResolved || len(db.incidents[0].StatusHistory) != 2 {
+		t.Fatalf("%#v", db.incidents[0])
+	}
+
+	//finished sagas are not repeated
+	err = ctrl.CreateIncident(ctx, incident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stops, starts := camunda.counts(); stops != 1 || starts != 1 || notifier.count != 1 {
+		t.Fatal(stops, starts, notifier.count)
+	}
+}
+
+func TestResumeIncidentSagas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &sagaTestDb{sagas: map[string]messages.IncidentSaga{}}
+	camunda := &sagaTestCamunda{}
+	ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-"}, camunda, db, sagaTestMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//interrupted after the incident has been saved
+	_ = db.SaveIncidentSaga(messages.IncidentSaga{
+		Id:       "incident1",
+		Incident: messages.Incident{Id: "incident1", ProcessDefinitionId: "d1", ProcessInstanceId: "i1"},
+		Steps: []messages.SagaStep{
+			{Action: messages.HandledActionStop, Done: true},
+			{Action: messages.SagaStepSave, Done: true},
+			{Action: messages.HandledActionRestart},
+			{Action: messages.SagaStepPublish},
+		},
+	})
+	//run by another replica
+	_ = db.SaveIncidentSaga(messages.IncidentSaga{
+		Id:         "incident2",
+		Owner:      "other",
+		LeaseUntil: time.Now().Add(time.Minute),
+		Incident:   messages.Incident{Id: "incident2", ProcessDefinitionId: "d2", ProcessInstanceId: "i2"},
+		Steps: []messages.SagaStep{
+			{Action: messages.HandledActionRestart},
+		},
+	})
+	//lease of a crashed replica expired
+	_ = db.SaveIncidentSaga(messages.IncidentSaga{
+		Id:         "incident3",
+		Owner:      "crashed",
+		LeaseUntil: time.Now().Add(-time.Second),
+		Incident:   messages.Incident{Id: "incident3", ProcessDefinitionId: "d3", ProcessInstanceId: "i3"},
+		Steps: []messages.SagaStep{
+			{Action: messages.HandledActionRestart},
+		},
+	})
+	running := &sync.WaitGroup{}
+	resumeCtx, stopResume := context.WithCancel(ctx)
+	err = ctrl.ResumeIncidentSagas(resumeCtx, running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopResume()
+	running.Wait()
+	saga, _, _ := db.GetIncidentSaga("incident1")
+	if stops, starts := camunda.counts(); stops != 0 || starts != 2 || !saga.Finished || saga.Owner != ctrl.owner {
+		t.Fatal(stops, starts, saga.Finished, saga.Owner)
+	}
+	if saga, _, _ = db.GetIncidentSaga("incident2"); saga.Finished || saga.Owner != "other" {
+		t.Fatal(saga.Finished, saga.Owner)
+	}
+	if saga, _, _ = db.GetIncidentSaga("incident3"); !saga.Finished || saga.Owner != ctrl.owner {
+		t.Fatal(saga.Finished, saga.Owner)
+	}
+
+	//a replica must not run a saga claimed by another one
+	err = ctrl.continueSaga(ctx, "incident2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, starts := camunda.counts(); starts != 2 {
+		t.Fatal(starts)
+	}
+}
+
+func TestIncidentSagaShardUnavailable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &sagaTestDb{sagas: map[string]messages.IncidentSaga{}}
+	camunda := &sagaTestCamunda{unavailableUntil: time.Now().Add(300 * time.Millisecond)}
+	ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-"}, camunda, db, sagaTestMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = db.SaveIncidentSaga(messages.IncidentSaga{
+		Id:       "incident1",
+		Incident: messages.Incident{Id: "incident1", ProcessDefinitionId: "d1", ProcessInstanceId: "i1"},
+		Steps: []messages.SagaStep{
+			{Action: messages.HandledActionStop, Done: true},
+			{Action: messages.SagaStepSave, Done: true},
+			{Action: messages.HandledActionRestart},
+			{Action: messages.SagaStepPublish},
+		},
+	})
+	err = ctrl.continueSaga(ctx, "incident1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saga, _, _ := db.GetIncidentSaga("incident1")
+	if saga.Finished || saga.Steps[2].Done || !saga.Steps[2].NotBefore.Equal(camunda.unavailableUntil) || saga.Steps[2].Error != "" {
+		t.Fatalf("%#v", saga.Steps[2])
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	saga, _, _ = db.GetIncidentSaga("incident1")
+	if _, starts := camunda.counts(); starts != 1 || !saga.Finished || saga.Steps[2].Target != "new-instance" {
+		t.Fatal(starts, saga.Finished, saga.Steps[2])
+	}
+}
+
+func TestIncidentSagaResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, resumeErr := range []error{nil, errors.New("activity not found")} {
+		db := &sagaTestDb{
+			handler: messages.OnIncident{ProcessDefinitionId: "d1", Restart: true, Mode: messages.OnIncidentModeResume},
+			sagas:   map[string]messages.IncidentSaga{},
+		}
+		camunda := &sagaTestCamunda{resumeErr: resumeErr}
+		ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-"}, camunda, db, sagaTestMetrics{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ctrl.CreateIncident(ctx, messages.Incident{Id: "incident1", ProcessDefinitionId: "d1", ProcessInstanceId: "i1", Time: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stops, starts := camunda.counts()
+		if resumeErr == nil && (camunda.resumes != 1 || stops != 0 || starts != 0 || db.incidents[0].HandlingMode != messages.OnIncidentModeResume || db.incidents[0].Status != messages.IncidentStatusAutoResolved) {
+			t.Fatalf("%v %v %v %#v", camunda.resumes, stops, starts, db.incidents[0])
+		}
+		//the fallback stops and restarts the process like the default mode
+		if resumeErr != nil && (camunda.resumes != 1 || stops != 1 || starts != 1 || db.incidents[0].HandlingMode != "" || db.incidents[0].Status != messages.IncidentStatusAutoResolved) {
+			t.Fatalf("%v %v %v %#v", camunda.resumes, stops, starts, db.incidents[0])
+		}
+	}
+}
+
+func TestIncidentSagaDigest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &sagaTestDb{
+		handler: messages.OnIncident{ProcessDefinitionId: "d1", Notify: true},
+		sagas:   map[string]messages.IncidentSaga{},
+	}
+	camunda := &sagaTestCamunda{}
+	ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-", NotificationDigestWindow: "200ms"}, camunda, db, sagaTestMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &sagaTestNotifier{}
+	ctrl.notifiers = map[string]notification.Notifier{"test": notifier}
+	ctrl.defaultChannels = []string{"test"}
+
+	err = ctrl.CreateIncident(ctx, messages.Incident{Id: "incident1", ProcessDefinitionId: "d1", ProcessInstanceId: "i1", TenantId: "user", ErrorMessage: "error", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the digest step waits for the end of the digest window, the following steps are not delayed
+	saga, _, _ := db.GetIncidentSaga("incident1")
+	if stops, _ := camunda.counts(); saga.Finished || saga.Steps[0].Action != messages.HandledActionDigest || saga.Steps[0].Done || !saga.Steps[len(saga.Steps)-1].Done || stops != 1 || notifier.sent() != 0 {
+		t.Fatalf("%v %#v", stops, saga)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	saga, _, _ = db.GetIncidentSaga("incident1")
+	if !saga.Finished || !saga.Steps[0].Done || notifier.sent() != 1 {
+		t.Fatalf("%v %#v", notifier.sent(), saga)
+	}
+}
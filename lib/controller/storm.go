@@ -0,0 +1,74 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const DefaultIncidentStormWindow = time.Minute
+
+// IncidentRateDetector counts events per key within a sliding window
+type IncidentRateDetector struct {
+	window      time.Duration
+	mux         sync.Mutex
+	events      map[string][]rateEvent
+	lastCleanup time.Time
+}
+
+type rateEvent struct {
+	id string
+	at time.Time
+}
+
+func NewIncidentRateDetector(window time.Duration) *IncidentRateDetector {
+	return &IncidentRateDetector{window: window, events: map[string][]rateEvent{}}
+}
+
+// Add registers the event id for the key and returns the count of events within the window (including the new one).
+// an id that has already been registered within the window is counted once, e.g. a retried incident
+func (this *IncidentRateDetector) Add(key string, id string, now time.Time) int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if now.Sub(this.lastCleanup) > this.window {
+		for k, list := range this.events {
+			if len(list) == 0 || now.Sub(list[len(list)-1].at) >= this.window {
+				delete(this.events, k)
+			}
+		}
+		this.lastCleanup = now
+	}
+	list := this.events[key]
+	i := 0
+	for i < len(list) && now.Sub(list[i].at) >= this.window {
+		i++
+	}
+	list = list[i:]
+	if !slices.ContainsFunc(list, func(event rateEvent) bool { return event.id == id }) {
+		list = append(list, rateEvent{id: id, at: now})
+	}
+	this.events[key] = list
+	return len(list)
+}
+
+func (this *IncidentRateDetector) Reset(key string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.events, key)
+}
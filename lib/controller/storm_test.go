@@ -0,0 +1,154 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/notification"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIncidentRateDetector(t *testing.T) {
+	detector := NewIncidentRateDetector(time.Minute)
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		if count := detector.Add("a", strconv.Itoa(i), now.Add(time.Duration(i)*10*time.Second)); count != i {
+			t.Fatal(i, count)
+		}
+	}
+	if count := detector.Add("b", "1", now); count != 1 {
+		t.Fatal(count)
+	}
+	//first event of "a" (now+10s) is outside of the window
+	if count := detector.Add("a", "4", now.Add(75*time.Second)); count != 3 {
+		t.Fatal(count)
+	}
+	//retried event
+	if count := detector.Add("a", "4", now.Add(76*time.Second)); count != 3 {
+		t.Fatal(count)
+	}
+	detector.Reset("a")
+	if count := detector.Add("a", "4", now.Add(80*time.Second)); count != 1 {
+		t.Fatal(count)
+	}
+}
+
+type stormTestDb struct {
+	*sagaTestDb
+	suspensions  map[string]messages.DefinitionSuspension
+	saveSagaErrs int
+}
+
+func (this *stormTestDb) SaveIncidentSaga(saga messages.IncidentSaga) error {
+	this.mux.Lock()
+	if this.saveSagaErrs > 0 {
+		this.saveSagaErrs--
+		this.mux.Unlock()
+		return errors.New("test error")
+	}
+	this.mux.Unlock()
+	return this.sagaTestDb.SaveIncidentSaga(saga)
+}
+
+func (this *stormTestDb) SaveDefinitionSuspension(suspension messages.DefinitionSuspension) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.suspensions[suspension.ProcessDefinitionId] = suspension
+	return nil
+}
+
+func (this *stormTestDb) GetDefinitionSuspension(definitionId string) (messages.DefinitionSuspension, bool, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	suspension, ok := this.suspensions[definitionId]
+	return suspension, ok, nil
+}
+
+type stormTestCamunda struct {
+	*sagaTestCamunda
+	suspends int
+}
+
+func (this *stormTestCamunda) SetProcessDefinitionSuspended(ctx context.Context, id string, tenantId string, suspended bool) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.suspends++
+	return nil
+}
+
+func TestIncidentStormRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &stormTestDb{
+		sagaTestDb: &sagaTestDb{
+			handler: messages.OnIncident{ProcessDefinitionId: "d1", Notify: true},
+			sagas:   map[string]messages.IncidentSaga{},
+		},
+		suspensions: map[string]messages.DefinitionSuspension{},
+	}
+	camunda := &stormTestCamunda{sagaTestCamunda: &sagaTestCamunda{}}
+	ctrl, err := New(ctx, configuration.Config{IncidentDedupWindow: "-", IncidentStormThreshold: 2}, camunda, db, sagaTestMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &sagaTestNotifier{}
+	ctrl.notifiers = map[string]notification.Notifier{"test": notifier}
+	ctrl.defaultChannels = []string{"test"}
+
+	newIncident := func(id string) messages.Incident {
+		return messages.Incident{Id: id, ProcessDefinitionId: "d1", ProcessInstanceId: "i-" + id, TenantId: "user", ErrorMessage: "error", Time: time.Now()}
+	}
+	err = ctrl.CreateIncident(ctx, newIncident("incident1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//storm detected, the saga can not be saved
+	db.saveSagaErrs = 1
+	err = ctrl.CreateIncident(ctx, newIncident("incident2"))
+	if err == nil {
+		t.Fatal("expected save error")
+	}
+	if camunda.suspends != 0 || notifier.sent() != 1 || db.suspensions["d1"].IncidentId != "incident2" {
+		t.Fatal(camunda.suspends, notifier.sent(), db.suspensions)
+	}
+
+	//the retry repeats the suspension without counting the incident again
+	err = ctrl.CreateIncident(ctx, newIncident("incident2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saga, _, _ := db.GetIncidentSaga("incident2")
+	if camunda.suspends != 1 || notifier.sent() != 2 || !saga.Finished || db.suspensions["d1"].IncidentCount != 2 {
+		t.Fatal(camunda.suspends, notifier.sent(), saga.Finished, db.suspensions)
+	}
+
+	//later incidents of the suspended definition
+	err = ctrl.CreateIncident(ctx, newIncident("incident3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if camunda.suspends != 1 || notifier.sent() != 2 {
+		t.Fatal(camunda.suspends, notifier.sent())
+	}
+}
@@ -18,6 +18,7 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -30,6 +31,51 @@ func (this *Mongo) SaveIncident(incident messages.Incident) error {
 	return err
 }
 
+func (this *Mongo) GetIncident(id string) (incident messages.Incident, exists bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	err = this.incidentsCollection().FindOne(ctx, bson.M{"id": id}).Decode(&incident)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return incident, false, nil
+	}
+	if err != nil {
+		return incident, false, err
+	}
+	return incident, true, nil
+}
+
+// SetIncidentStatus updates the status if the incident still has the status transition.From
+// and appends the transition to the status history; updated is false if the status has changed in the meantime
+func (this *Mongo) SetIncidentStatus(id string, transition messages.IncidentStatusTransition) (updated bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	filter := bson.M{"id": id, "status": transition.From}
+	if transition.From == messages.IncidentStatusOpen {
+		filter["status"] = bson.M{"$in": bson.A{messages.IncidentStatusOpen, nil}} //incidents stored without status are open
+	}
+	set := bson.M{"status": transition.To}
+	switch transition.To {
+	case messages.IncidentStatusAcknowledged:
+		set["acknowledged_at"] = transition.Time
+		set["acknowledged_by"] = transition.Actor
+	case messages.IncidentStatusResolved, messages.IncidentStatusAutoResolved:
+		set["resolved_at"] = transition.Time
+		set["resolved_by"] = transition.Actor
+	}
+	result, err := this.incidentsCollection().UpdateOne(ctx, filter, bson.M{"$set": set, "$push": bson.M{"status_history": transition}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (this *Mongo) SetIncidentHandlingMode(id string, mode string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	_, err := this.incidentsCollection().UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"handling_mode": mode}})
+	return err
+}
+
 func (this *Mongo) DeleteIncidentByInstanceId(id string) error {
 	ctx, _ := context.WithTimeout(context.Background(), TIMEOUT)
 	_, err := this.incidentsCollection().DeleteMany(ctx, bson.M{"process_instance_id": id})
@@ -24,6 +24,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"reflect"
 	"strings"
+	"time"
 )
 
 func getBsonFieldObject[T any]() T {
@@ -140,3 +141,14 @@ func (this *Mongo) ensureTextIndex(collection *mongo.Collection, indexname strin
 	})
 	return err
 }
+
+// ensureTtlIndex removes documents expireAfter after the time in indexKey; documents without the field are kept
+func (this *Mongo) ensureTtlIndex(collection *mongo.Collection, indexname string, indexKey string, expireAfter time.Duration) error {
+	ctx, cancel := this.getTimeoutContext()
+	defer cancel()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: indexKey, Value: 1}},
+		Options: options.Index().SetName(indexname).SetExpireAfterSeconds(int32(expireAfter.Seconds())),
+	})
+	return err
+}
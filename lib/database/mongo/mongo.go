@@ -19,14 +19,18 @@ package mongo
 import (
 	"context"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"log"
 	"time"
 )
 
 const TIMEOUT = 10 * time.Second
 
+const DefaultIncidentSagaRetention = 24 * time.Hour
+
 type Mongo struct {
 	config configuration.Config
 	client *mongo.Client
@@ -50,6 +54,20 @@ func New(ctx context.Context, config configuration.Config) (result *Mongo, err e
 	return result, result.initIndexes()
 }
 
+// Close disconnects the client; the client is also disconnected when the ctx of New is done
+func (this *Mongo) Close() error {
+	log.Println("disconnect mongodb")
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	return this.client.Disconnect(ctx)
+}
+
+func (this *Mongo) RegisterHealthChecks(registry interfaces.HealthRegistry) {
+	registry.Register("mongo", func(ctx context.Context) error {
+		return this.client.Ping(ctx, readpref.Primary())
+	})
+}
+
 func (this *Mongo) getTimeoutContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(this.ctx, TIMEOUT)
 }
@@ -62,6 +80,47 @@ func (this *Mongo) initIndexes() error {
 	if err != nil {
 		return err
 	}
+	err = this.ensureIndex(this.onIncidentsCollection(), "on_incident_tenant_id_index", OnIncidentBson.TenantId, true, false)
+	if err != nil {
+		return err
+	}
+
+	// suspension indexes
+	err = this.ensureIndex(this.suspensionsCollection(), "suspension_process_definition_id_index", DefinitionSuspensionBson.ProcessDefinitionId, true, true)
+	if err != nil {
+		return err
+	}
+
+	// outbox indexes
+	err = this.ensureIndex(this.outboxCollection(), "outbox_id_index", OutboxEventBson.Id, true, true)
+	if err != nil {
+		return err
+	}
+	err = this.ensureIndex(this.outboxCollection(), "outbox_created_at_index", OutboxEventCreatedAtBson, true, false)
+	if err != nil {
+		return err
+	}
+
+	// saga indexes
+	err = this.ensureIndex(this.sagasCollection(), "saga_id_index", IncidentSagaBson.Id, true, true)
+	if err != nil {
+		return err
+	}
+	err = this.ensureIndex(this.sagasCollection(), "saga_finished_index", IncidentSagaFinishedBson, true, false)
+	if err != nil {
+		return err
+	}
+	retention := DefaultIncidentSagaRetention
+	if this.config.IncidentSagaRetention != "" {
+		retention, err = time.ParseDuration(this.config.IncidentSagaRetention)
+		if err != nil {
+			return err
+		}
+	}
+	err = this.ensureTtlIndex(this.sagasCollection(), "saga_finished_at_ttl_index", IncidentSagaFinishedAtBson, retention)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -70,5 +129,9 @@ func (this *Mongo) DeleteByDefinitionId(id string) error {
 	if err != nil {
 		return err
 	}
-	return this.DeleteOnIncidentByDefinitionId(id)
+	err = this.DeleteOnIncidentByDefinitionId(id)
+	if err != nil {
+		return err
+	}
+	return this.DeleteDefinitionSuspension(id)
 }
@@ -18,6 +18,7 @@ package mongo
 
 import (
 	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -26,12 +27,49 @@ import (
 
 var OnIncidentBson = getBsonFieldObject[messages.OnIncident]()
 
+var OnIncidentRestartStateBson, _ = getBsonFieldName(messages.OnIncident{}, "RestartState")
+var RestartStateVersionBson, _ = getBsonFieldName(messages.RestartState{}, "Version")
+
 func (this *Mongo) SaveOnIncident(handler messages.OnIncident) error {
 	ctx, _ := context.WithTimeout(context.Background(), TIMEOUT)
 	_, err := this.onIncidentsCollection().ReplaceOne(ctx, bson.M{OnIncidentBson.ProcessDefinitionId: handler.ProcessDefinitionId}, handler, options.Replace().SetUpsert(true))
 	return err
 }
 
+// UpdateOnIncidentRestartState is a compare-and-set on the version of the restart state, so that replicas can not use the same budget
+func (this *Mongo) UpdateOnIncidentRestartState(definitionId string, version int64, state messages.RestartState) (updated bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	var expectedVersion interface{} = version
+	if version == 0 {
+		//handlers without restart state
+		expectedVersion = bson.M{"$in": bson.A{0, nil}}
+	}
+	state.Version = version + 1
+	result, err := this.onIncidentsCollection().UpdateOne(ctx, bson.M{
+		OnIncidentBson.ProcessDefinitionId:                         definitionId,
+		OnIncidentRestartStateBson + "." + RestartStateVersionBson: expectedVersion,
+	}, bson.M{"$set": bson.M{OnIncidentRestartStateBson: state}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// SetOnIncidentTenantId assigns a handler without tenant to the tenant; handlers of other tenants are not matched
+func (this *Mongo) SetOnIncidentTenantId(definitionId string, tenantId string) (updated bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	result, err := this.onIncidentsCollection().UpdateOne(ctx, bson.M{
+		OnIncidentBson.ProcessDefinitionId: definitionId,
+		OnIncidentBson.TenantId:            bson.M{"$in": bson.A{"", nil}},
+	}, bson.M{"$set": bson.M{OnIncidentBson.TenantId: tenantId}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
 func (this *Mongo) DeleteOnIncidentByDefinitionId(definitionId string) error {
 	ctx, _ := context.WithTimeout(context.Background(), TIMEOUT)
 	_, err := this.onIncidentsCollection().DeleteMany(ctx, bson.M{OnIncidentBson.ProcessDefinitionId: definitionId})
@@ -57,3 +95,29 @@ func (this *Mongo) GetOnIncident(definitionId string) (handler messages.OnIncide
 	}
 	return handler, true, err
 }
+
+// ListOnIncidents returns the handlers sorted by process-definition id
+func (this *Mongo) ListOnIncidents(query interfaces.OnIncidentQuery) (handlers []messages.OnIncident, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	filter := bson.M{}
+	if query.WithoutTenant {
+		filter[OnIncidentBson.TenantId] = bson.M{"$in": bson.A{"", nil}}
+	} else if query.TenantId != "" {
+		filter[OnIncidentBson.TenantId] = query.TenantId
+	}
+	if query.ProcessDefinitionId != "" {
+		filter[OnIncidentBson.ProcessDefinitionId] = query.ProcessDefinitionId
+	}
+	opt := options.Find().SetSort(bson.D{{Key: OnIncidentBson.ProcessDefinitionId, Value: 1}}).SetSkip(query.Offset)
+	if query.Limit > 0 {
+		opt.SetLimit(query.Limit)
+	}
+	cursor, err := this.onIncidentsCollection().Find(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+	handlers = []messages.OnIncident{}
+	err = cursor.All(ctx, &handlers)
+	return handlers, err
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var OutboxEventBson = getBsonFieldObject[messages.OutboxEvent]()
+
+var OutboxEventCreatedAtBson, _ = getBsonFieldName(messages.OutboxEvent{}, "CreatedAt")
+
+func (this *Mongo) SaveOutboxEvent(event messages.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	_, err := this.outboxCollection().ReplaceOne(ctx, bson.M{OutboxEventBson.Id: event.Id}, event, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ListOutboxEvents returns the oldest events first
+func (this *Mongo) ListOutboxEvents(limit int64) (events []messages.OutboxEvent, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	cursor, err := this.outboxCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: OutboxEventCreatedAtBson, Value: 1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	events = []messages.OutboxEvent{}
+	err = cursor.All(ctx, &events)
+	return events, err
+}
+
+func (this *Mongo) DeleteOutboxEvent(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	_, err := this.outboxCollection().DeleteOne(ctx, bson.M{OutboxEventBson.Id: id})
+	return err
+}
+
+func (this *Mongo) outboxCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoDatabaseName).Collection(this.config.MongoOutboxCollectionName)
+}
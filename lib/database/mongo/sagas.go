@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var IncidentSagaBson = getBsonFieldObject[messages.IncidentSaga]()
+
+var IncidentSagaFinishedBson, _ = getBsonFieldName(messages.IncidentSaga{}, "Finished")
+var IncidentSagaFinishedAtBson, _ = getBsonFieldName(messages.IncidentSaga{}, "FinishedAt")
+var IncidentSagaCreatedAtBson, _ = getBsonFieldName(messages.IncidentSaga{}, "CreatedAt")
+var IncidentSagaOwnerBson, _ = getBsonFieldName(messages.IncidentSaga{}, "Owner")
+var IncidentSagaLeaseUntilBson, _ = getBsonFieldName(messages.IncidentSaga{}, "LeaseUntil")
+
+// SaveIncidentSaga replaces the saga if it has no other owner; sagas of other owners are not matched
+// and the upsert is rejected by the unique id index
+func (this *Mongo) SaveIncidentSaga(saga messages.IncidentSaga) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	filter := bson.M{IncidentSagaBson.Id: saga.Id, IncidentSagaOwnerBson: bson.M{"$in": bson.A{saga.Owner, nil}}}
+	_, err := this.sagasCollection().ReplaceOne(ctx, filter, saga, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return interfaces.ErrSagaClaimed
+	}
+	return err
+}
+
+func (this *Mongo) ClaimIncidentSaga(id string, owner string, leaseUntil time.Time) (saga messages.IncidentSaga, claimed bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	filter := bson.M{
+		IncidentSagaBson.Id:      id,
+		IncidentSagaFinishedBson: false,
+		"$or": bson.A{
+			bson.M{IncidentSagaOwnerBson: bson.M{"$in": bson.A{owner, nil}}},
+			bson.M{IncidentSagaLeaseUntilBson: bson.M{"$lt": time.Now()}},
+		},
+	}
+	update := bson.M{"$set": bson.M{IncidentSagaOwnerBson: owner, IncidentSagaLeaseUntilBson: leaseUntil}}
+	err = this.sagasCollection().FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&saga)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return saga, false, nil
+	}
+	if err != nil {
+		return saga, false, err
+	}
+	return saga, true, nil
+}
+
+func (this *Mongo) GetIncidentSaga(id string) (saga messages.IncidentSaga, exists bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	err = this.sagasCollection().FindOne(ctx, bson.M{IncidentSagaBson.Id: id}).Decode(&saga)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return saga, false, nil
+	}
+	if err != nil {
+		return saga, false, err
+	}
+	return saga, true, nil
+}
+
+// ListUnfinishedIncidentSagas returns the oldest sagas first
+func (this *Mongo) ListUnfinishedIncidentSagas() (sagas []messages.IncidentSaga, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	cursor, err := this.sagasCollection().Find(ctx, bson.M{IncidentSagaFinishedBson: false}, options.Find().SetSort(bson.D{{Key: IncidentSagaCreatedAtBson, Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	sagas = []messages.IncidentSaga{}
+	err = cursor.All(ctx, &sagas)
+	return sagas, err
+}
+
+func (this *Mongo) sagasCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoDatabaseName).Collection(this.config.MongoSagaCollectionName)
+}
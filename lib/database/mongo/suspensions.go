@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var DefinitionSuspensionBson = getBsonFieldObject[messages.DefinitionSuspension]()
+
+func (this *Mongo) SaveDefinitionSuspension(suspension messages.DefinitionSuspension) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	_, err := this.suspensionsCollection().ReplaceOne(ctx, bson.M{DefinitionSuspensionBson.ProcessDefinitionId: suspension.ProcessDefinitionId}, suspension, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (this *Mongo) GetDefinitionSuspension(definitionId string) (suspension messages.DefinitionSuspension, exists bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	err = this.suspensionsCollection().FindOne(ctx, bson.M{DefinitionSuspensionBson.ProcessDefinitionId: definitionId}).Decode(&suspension)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return suspension, false, nil
+	}
+	if err != nil {
+		return suspension, false, err
+	}
+	return suspension, true, nil
+}
+
+func (this *Mongo) DeleteDefinitionSuspension(definitionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TIMEOUT)
+	defer cancel()
+	_, err := this.suspensionsCollection().DeleteMany(ctx, bson.M{DefinitionSuspensionBson.ProcessDefinitionId: definitionId})
+	return err
+}
+
+func (this *Mongo) suspensionsCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoDatabaseName).Collection(this.config.MongoSuspensionCollectionName)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Handle stops a started worker; canceling the ctx of Start stops the worker without waiting for in-flight incidents
+type Handle struct {
+	stopIntake    context.CancelFunc    //kafka consumers and camunda polling
+	stopWorkers   context.CancelFunc    //controller (saga timers, digests) and outbox
+	stopResources context.CancelFunc    //mongo, postgres and metrics
+	running       *sync.WaitGroup       //in-flight incidents; waited for before the workers are stopped
+	workers       []interface{ Wait() } //background workers (outbox, saga timers, digests); waited for after they are stopped and before the closers
+	closers       []io.Closer           //closed in reverse order of creation after the workers have stopped
+	timeout       time.Duration
+	once          sync.Once
+}
+
+func (this *Handle) addCloser(value interface{}) {
+	if closer, ok := value.(io.Closer); ok {
+		this.closers = append(this.closers, closer)
+	}
+}
+
+// Stop cancels the intake, waits up to the configured shutdown timeout for in-flight incidents and their commits,
+// stops the background workers, waits up to the timeout for them to return and then closes mongo, postgres and the metrics server.
+// an error is returned if the timeout is reached or a connection could not be closed
+func (this *Handle) Stop() (err error) {
+	this.once.Do(func() {
+		log.Println("stop intake of incidents")
+		this.stopIntake()
+		drained := make(chan struct{})
+		go func() {
+			this.running.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+			log.Println("in-flight incidents finished")
+		case <-time.After(this.timeout):
+			err = errors.New("shutdown timeout reached before in-flight incidents finished")
+		}
+		this.stopWorkers()
+		stopped := make(chan struct{})
+		go func() {
+			for _, worker := range this.workers {
+				worker.Wait()
+			}
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(this.timeout):
+			err = errors.Join(err, errors.New("shutdown timeout reached before background workers stopped"))
+		}
+		err = errors.Join(err, this.closeResources())
+	})
+	return err
+}
+
+// abort stops a partially started worker: the already created resources are closed before err is returned
+func (this *Handle) abort(err error) error {
+	this.stopIntake()
+	this.stopWorkers()
+	closeErr := this.closeResources()
+	if closeErr != nil {
+		log.Println("WARNING: unable to close resources after start error:", closeErr)
+	}
+	return err
+}
+
+func (this *Handle) closeResources() (err error) {
+	for i := len(this.closers) - 1; i >= 0; i-- {
+		err = errors.Join(err, this.closers[i].Close())
+	}
+	this.stopResources()
+	return err
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type handleTestCloser struct {
+	name   string
+	closed *[]string
+}
+
+func (this handleTestCloser) Close() error {
+	*this.closed = append(*this.closed, this.name)
+	return nil
+}
+
+func TestHandleStop(t *testing.T) {
+	closed := []string{}
+	intakeCtx, stopIntake := context.WithCancel(context.Background())
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	handle := &Handle{stopIntake: stopIntake, stopWorkers: stopWorkers, stopResources: func() {}, running: &sync.WaitGroup{}, timeout: time.Second}
+	handle.addCloser(handleTestCloser{name: "mongo", closed: &closed})
+	handle.addCloser(handleTestCloser{name: "postgres", closed: &closed})
+	handle.addCloser("not a closer")
+
+	finished := false
+	handle.running.Add(1)
+	go func() {
+		defer handle.running.Done()
+		<-intakeCtx.Done()
+		time.Sleep(100 * time.Millisecond) //in-flight incident
+		if workerCtx.Err() != nil {
+			t.Error("workers stopped before in-flight incident finished")
+		}
+		finished = true
+	}()
+
+	workerStopped := false
+	worker := &sync.WaitGroup{}
+	handle.workers = append(handle.workers, worker)
+	worker.Add(1)
+	go func() {
+		defer worker.Done()
+		<-workerCtx.Done()
+		time.Sleep(100 * time.Millisecond) //e.g. outbox batch
+		if len(closed) != 0 {
+			t.Error("resources closed before background worker stopped")
+		}
+		workerStopped = true
+	}()
+
+	err := handle.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !workerStopped {
+		t.Fatal("stop returned before background worker stopped")
+	}
+	//reverse order of creation
+	if !finished || workerCtx.Err() == nil || len(closed) != 2 || closed[0] != "postgres" || closed[1] != "mongo" {
+		t.Fatal(finished, workerCtx.Err(), closed)
+	}
+	if handle.Stop() != nil || len(closed) != 2 {
+		t.Fatal("second stop must be a no-op")
+	}
+}
+
+func TestHandleStopTimeout(t *testing.T) {
+	handle := &Handle{stopIntake: func() {}, stopWorkers: func() {}, stopResources: func() {}, running: &sync.WaitGroup{}, timeout: 50 * time.Millisecond}
+	handle.running.Add(1) //never finished
+	if handle.Stop() == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestHandleStopWorkerTimeout(t *testing.T) {
+	handle := &Handle{stopIntake: func() {}, stopWorkers: func() {}, stopResources: func() {}, running: &sync.WaitGroup{}, timeout: 50 * time.Millisecond}
+	worker := &sync.WaitGroup{}
+	worker.Add(1) //never finished
+	handle.workers = append(handle.workers, worker)
+	if handle.Stop() == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestHandleAbort(t *testing.T) {
+	closed := []string{}
+	resourceCtx, stopResources := context.WithCancel(context.Background())
+	workerCtx, stopWorkers := context.WithCancel(resourceCtx)
+	handle := &Handle{stopIntake: func() {}, stopWorkers: stopWorkers, stopResources: stopResources, running: &sync.WaitGroup{}, timeout: time.Second}
+	handle.addCloser(handleTestCloser{name: "postgres", closed: &closed})
+	handle.addCloser(handleTestCloser{name: "mongo", closed: &closed})
+	handle.addCloser(handleTestCloser{name: "metrics", closed: &closed})
+
+	err := handle.abort(errors.New("start error"))
+	if err == nil || err.Error() != "start error" {
+		t.Fatal(err)
+	}
+	if workerCtx.Err() == nil || resourceCtx.Err() == nil || !reflect.DeepEqual(closed, []string{"metrics", "mongo", "postgres"}) {
+		t.Fatal(workerCtx.Err(), resourceCtx.Err(), closed)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const CheckTimeout = 5 * time.Second
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check returns nil if the dependency is usable
+type Check func(ctx context.Context) error
+
+// Registry collects the checks of the subsystems; implements interfaces.HealthRegistry
+type Registry struct {
+	mux    sync.Mutex
+	checks map[string]Check
+}
+
+type Report struct {
+	Status string                 `json:"status"` //StatusUp if all checks are up
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+func New() *Registry {
+	return &Registry{checks: map[string]Check{}}
+}
+
+// Register adds or replaces the check with the given name
+func (this *Registry) Register(name string, check func(ctx context.Context) error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.checks[name] = check
+}
+
+// Check runs all checks in parallel; each check is canceled after CheckTimeout
+func (this *Registry) Check(ctx context.Context) (report Report) {
+	this.mux.Lock()
+	names := []string{}
+	for name := range this.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := []Check{}
+	for _, name := range names {
+		checks = append(checks, this.checks[name])
+	}
+	this.mux.Unlock()
+
+	results := make([]CheckResult, len(checks))
+	wg := sync.WaitGroup{}
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, CheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := check(checkCtx)
+			results[i] = CheckResult{Status: StatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				results[i].Status = StatusDown
+				results[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	report = Report{Status: StatusUp, Checks: map[string]CheckResult{}}
+	for i, result := range results {
+		report.Checks[names[i]] = result
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// ServeLive answers as long as the process is able to handle requests
+func (this *Registry) ServeLive(writer http.ResponseWriter, request *http.Request) {
+	writeReport(writer, http.StatusOK, Report{Status: StatusUp})
+}
+
+// ServeReady runs all checks; the status is 503 if a check is down
+func (this *Registry) ServeReady(writer http.ResponseWriter, request *http.Request) {
+	report := this.Check(request.Context())
+	code := http.StatusOK
+	if report.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	writeReport(writer, code, report)
+}
+
+func writeReport(writer http.ResponseWriter, code int, report Report) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(report)
+}
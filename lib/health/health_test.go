@@ -0,0 +1,74 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	registry := New()
+	registry.Register("mongo", func(ctx context.Context) error {
+		return nil
+	})
+	registry.Register("kafka", func(ctx context.Context) error {
+		return errors.New("not a member of the consumer group")
+	})
+	server := httptest.NewServer(http.HandlerFunc(registry.ServeReady))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	report := Report{}
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatal(resp.StatusCode, report)
+	}
+	if report.Checks["mongo"].Status != StatusUp || report.Checks["kafka"].Status != StatusDown || report.Checks["kafka"].Error != "not a member of the consumer group" {
+		t.Fatalf("%#v", report.Checks)
+	}
+
+	registry.Register("kafka", func(ctx context.Context) error {
+		return nil
+	})
+	if report = registry.Check(context.Background()); report.Status != StatusUp || len(report.Checks) != 2 {
+		t.Fatalf("%#v", report)
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	registry := New()
+	registry.Register("mongo", func(ctx context.Context) error {
+		return errors.New("unavailable")
+	})
+	recorder := httptest.NewRecorder()
+	registry.ServeLive(recorder, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatal(recorder.Code)
+	}
+}
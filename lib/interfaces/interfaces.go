@@ -18,19 +18,103 @@ package interfaces
 
 import (
 	"context"
+	"errors"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"sync"
+	"time"
 )
 
+// ErrInvalidMessage is wrapped by errors of HandleIncidentMessage for messages that can never be handled; they are not retried
+var ErrInvalidMessage = errors.New("invalid message")
+
+// ErrSagaClaimed is returned by Database.SaveIncidentSaga if the saga is owned by another replica
+var ErrSagaClaimed = errors.New("incident saga claimed by another replica")
+
+// ErrShardUnavailable is wrapped by ShardUnavailableError
+var ErrShardUnavailable = errors.New("camunda shard unavailable")
+
+// ShardUnavailableError is returned by Camunda while the circuit breaker of the shard is open; calls are possible again at RetryAt
+type ShardUnavailableError struct {
+	Shard   string
+	RetryAt time.Time
+}
+
+func (this ShardUnavailableError) Error() string {
+	return ErrShardUnavailable.Error() + ": " + this.Shard + " until " + this.RetryAt.Format(time.RFC3339)
+}
+
+func (this ShardUnavailableError) Unwrap() error {
+	return ErrShardUnavailable
+}
+
 type Controller interface {
-	HandleIncidentMessage(incident []byte) error
+	HandleIncidentMessage(ctx context.Context, incident []byte) error //ctx carries the span of the message
+	GetMessageOrderingKey(msg []byte) string                          //messages with the same key are handled in order; messages with an empty key are handled alone
+}
+
+type ConsumerMetrics interface {
+	NotifyConsumedMessage(topic string, result string, duration time.Duration)
+	SetConsumerLag(topic string, partition int, lag int64)
+	SetConsumerInFlight(topic string, count int)
+}
+
+type CamundaMetrics interface {
+	NotifyCamundaRequest(shard string, method string, status string, duration time.Duration)
+}
+
+// CamundaMetricsUser is implemented by Camunda implementations that report their requests
+type CamundaMetricsUser interface {
+	SetCamundaMetrics(metrics CamundaMetrics)
 }
 
+// Camunda methods use ctx for tracing and to cancel their requests
 type Camunda interface {
-	StopProcessInstance(id string, tenantId string) (err error)
-	GetProcessName(id string, tenantId string) (string, error)
-	StartProcess(processDefinitionId string, userId string) (err error)
-	GetIncidents() (result []messages.CamundaIncident, err error)
+	StopProcessInstance(ctx context.Context, id string, tenantId string) (err error)
+	ResumeProcessInstance(ctx context.Context, id string, tenantId string, externalTaskId string, targetActivityId string) (err error)
+	GetProcessName(ctx context.Context, id string, tenantId string) (string, error)
+	IsProcessDefinitionOwner(ctx context.Context, id string, tenantId string) (bool, error) //false if the definition is unknown or deployed by another tenant
+	ListProcessDefinitionIds(ctx context.Context, tenantId string) ([]string, error)        //process-definitions deployed by the tenant
+	SetProcessDefinitionSuspended(ctx context.Context, id string, tenantId string, suspended bool) (err error)
+	StartProcess(ctx context.Context, processDefinitionId string, userId string, variables map[string]interface{}) (processInstanceId string, err error)
+	GetProcessInstanceVariables(ctx context.Context, id string, tenantId string) (variables map[string]interface{}, err error)
+	GetIncidents(ctx context.Context, query CamundaIncidentQuery) (result []messages.CamundaIncident, report map[string]CamundaShardReport, err error) //result contains the incidents of all shards without error in report; err is only set if the shards are unknown
+}
+
+// CamundaIncidentQuery filters Camunda.GetIncidents; empty fields are ignored
+type CamundaIncidentQuery struct {
+	IncidentType string    //e.g. "failedExternalTask"
+	After        time.Time //only incidents created after this time
+	PageSize     int       //incidents per request; defaults to DefaultCamundaIncidentPageSize
+}
+
+const DefaultCamundaIncidentPageSize = 100
+
+// CamundaShardReport is the result of one shard in Camunda.GetIncidents
+type CamundaShardReport struct {
+	Incidents int
+	Duration  time.Duration
+	Err       error
+}
+
+// OnIncidentQuery filters Database.ListOnIncidents; empty fields are ignored
+type OnIncidentQuery struct {
+	TenantId            string
+	WithoutTenant       bool //only handlers without tenant (e.g. saved by the kafka HANDLER command); TenantId is ignored
+	ProcessDefinitionId string
+	Limit               int64 //0 returns all handlers
+	Offset              int64
+}
+
+// LeaderLock is held by at most one replica
+type LeaderLock interface {
+	TryLock(ctx context.Context) (held bool, err error) //acquires the lock or checks that it is still held
+	Unlock() error
+}
+
+// LeaderLockProvider is implemented by Camunda implementations that provide locks shared by all replicas
+type LeaderLockProvider interface {
+	NewLeaderLock(name string) LeaderLock
 }
 
 type CamundaFactory interface {
@@ -40,15 +124,49 @@ type CamundaFactory interface {
 type Database interface {
 	DeleteByDefinitionId(id string) error
 	SaveIncident(incident messages.Incident) error
+	GetIncident(id string) (incident messages.Incident, exists bool, err error)
+	SetIncidentStatus(id string, transition messages.IncidentStatusTransition) (updated bool, err error)
+	SetIncidentHandlingMode(id string, mode string) error
 	DeleteIncidentByInstanceId(id string) error
 	SaveOnIncident(handler messages.OnIncident) error
 	GetOnIncident(definitionId string) (incident messages.OnIncident, exists bool, err error)
+	ListOnIncidents(query OnIncidentQuery) (handlers []messages.OnIncident, err error)
+	DeleteOnIncidentByDefinitionId(definitionId string) error
+	SetOnIncidentTenantId(definitionId string, tenantId string) (updated bool, err error)                                   //only sets the tenant of a handler without tenant
+	UpdateOnIncidentRestartState(definitionId string, version int64, state messages.RestartState) (updated bool, err error) //only updates if the stored state still has the version; the version of state is set to version+1
+	SaveDefinitionSuspension(suspension messages.DefinitionSuspension) error
+	GetDefinitionSuspension(definitionId string) (suspension messages.DefinitionSuspension, exists bool, err error)
+	DeleteDefinitionSuspension(definitionId string) error
+	SaveOutboxEvent(event messages.OutboxEvent) error
+	ListOutboxEvents(limit int64) (events []messages.OutboxEvent, err error)
+	DeleteOutboxEvent(id string) error
+	SaveIncidentSaga(saga messages.IncidentSaga) error                                                                     //returns ErrSagaClaimed if the stored saga has another owner
+	ClaimIncidentSaga(id string, owner string, leaseUntil time.Time) (saga messages.IncidentSaga, claimed bool, err error) //claims an unfinished saga without owner, of the same owner or with an expired lease
+	GetIncidentSaga(id string) (saga messages.IncidentSaga, exists bool, err error)
+	ListUnfinishedIncidentSagas() (sagas []messages.IncidentSaga, err error)
 }
 
 type DatabaseFactory interface {
 	Get(ctx context.Context, config configuration.Config) (Database, error)
 }
 
+// SourceOptions are optional dependencies of the sources
+type SourceOptions struct {
+	Metrics ConsumerMetrics
+	Running *sync.WaitGroup //Done after the consumers have stopped and finished their in-flight messages
+	Health  HealthRegistry
+}
+
+type HealthRegistry interface {
+	Register(name string, check func(ctx context.Context) error)
+}
+
+// HealthCheckProvider is implemented by subsystems that register their own readiness checks (e.g. Database or Camunda implementations)
+type HealthCheckProvider interface {
+	RegisterHealthChecks(registry HealthRegistry)
+}
+
 type SourceFactory interface {
-	Start(ctx context.Context, config configuration.Config, control Controller, runtimeErrorHandler func(err error)) (err error)
+	Start(ctx context.Context, config configuration.Config, control Controller, options SourceOptions, runtimeErrorHandler func(err error)) (err error)
+	ReplayDeadLetters(ctx context.Context, config configuration.Config) (count int, err error)
 }
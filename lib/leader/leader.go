@@ -0,0 +1,93 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package leader
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"log"
+	"time"
+)
+
+const DefaultInterval = 10 * time.Second
+
+type Metrics interface {
+	SetLeader(election string, leader bool)
+}
+
+// Run calls lead while this replica holds lock; the ctx of lead is canceled when the leadership is lost or ctx is done.
+// the lock is checked every interval, other replicas retry the election with the same interval.
+// Run returns after ctx is done and lead has returned
+func Run(ctx context.Context, election string, lock interfaces.LeaderLock, interval time.Duration, metrics Metrics, lead func(ctx context.Context)) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	setLeader(metrics, election, false)
+	for {
+		if tryLock(ctx, election, lock, interval) {
+			log.Println("elected as leader of", election)
+			setLeader(metrics, election, true)
+			leaderCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				lead(leaderCtx)
+			}()
+			for leading := true; leading; {
+				select {
+				case <-done:
+					leading = false
+				case <-time.After(interval):
+					leading = tryLock(ctx, election, lock, interval)
+				}
+			}
+			cancel()
+			<-done
+			err := lock.Unlock()
+			if err != nil {
+				log.Println("WARNING: unable to release leader lock of", election, err)
+			}
+			setLeader(metrics, election, false)
+			log.Println("leadership of", election, "ended")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func tryLock(ctx context.Context, election string, lock interfaces.LeaderLock, timeout time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	held, err := lock.TryLock(ctx)
+	if err != nil {
+		log.Println("WARNING: leader election", election, err)
+		return false
+	}
+	return held
+}
+
+func setLeader(metrics Metrics, election string, leader bool) {
+	if metrics != nil {
+		metrics.SetLeader(election, leader)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package leader
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLockState is shared by the locks of all replicas, like the advisory lock in postgres
+type testLockState struct {
+	mux    sync.Mutex
+	holder *testLock
+}
+
+type testLock struct {
+	state  *testLockState
+	broken atomic.Bool //simulates a lost db connection of the replica
+}
+
+func (this *testLock) TryLock(ctx context.Context) (bool, error) {
+	this.state.mux.Lock()
+	defer this.state.mux.Unlock()
+	if this.broken.Load() {
+		return false, context.DeadlineExceeded
+	}
+	if this.state.holder == nil {
+		this.state.holder = this
+	}
+	return this.state.holder == this, nil
+}
+
+func (this *testLock) Unlock() error {
+	this.state.mux.Lock()
+	defer this.state.mux.Unlock()
+	if this.state.holder == this {
+		this.state.holder = nil
+	}
+	return nil
+}
+
+type testMetrics struct {
+	leader atomic.Bool
+}
+
+func (this *testMetrics) SetLeader(election string, leader bool) {
+	this.leader.Store(leader)
+}
+
+func TestFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := &testLockState{}
+	interval := 20 * time.Millisecond
+	leading := atomic.Int64{}
+	overlap := atomic.Bool{}
+	lead := func(ctx context.Context) {
+		if leading.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		<-ctx.Done()
+		leading.Add(-1)
+	}
+
+	locks := []*testLock{{state: state}, {state: state}}
+	metrics := []*testMetrics{{}, {}}
+	wg := sync.WaitGroup{}
+	for i := range locks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Run(ctx, "test", locks[i], interval, metrics[i], lead)
+		}(i)
+	}
+
+	time.Sleep(10 * interval)
+	if leading.Load() != 1 || metrics[0].leader.Load() == metrics[1].leader.Load() {
+		t.Fatal(leading.Load(), metrics[0].leader.Load(), metrics[1].leader.Load())
+	}
+	first := 0
+	if metrics[1].leader.Load() {
+		first = 1
+	}
+	second := 1 - first
+
+	locks[first].broken.Store(true)
+	time.Sleep(10 * interval)
+	if leading.Load() != 1 || metrics[first].leader.Load() || !metrics[second].leader.Load() {
+		t.Fatal(leading.Load(), metrics[first].leader.Load(), metrics[second].leader.Load())
+	}
+	if overlap.Load() {
+		t.Fatal("more than one leader")
+	}
+
+	cancel()
+	wg.Wait()
+	if leading.Load() != 0 || state.holder != nil || metrics[second].leader.Load() {
+		t.Fatal(leading.Load(), state.holder, metrics[second].leader.Load())
+	}
+}
@@ -18,6 +18,7 @@ package lib
 
 import (
 	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/api"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/camunda"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/camundasource"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
@@ -25,44 +26,95 @@ import (
 	"github.com/SENERGY-Platform/process-incident-worker/lib/database"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/metrics"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/outbox"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/source"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
 	"log"
+	"sync"
+	"time"
 )
 
-func Start(ctx context.Context, config configuration.Config) (err error) {
+func Start(ctx context.Context, config configuration.Config) (handle *Handle, err error) {
 	return StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		log.Fatalf("FATAL: %+v", err)
 	})
 }
 
-func StartWith(parentCtx context.Context, config configuration.Config, source interfaces.SourceFactory, camunda interfaces.CamundaFactory, database interfaces.DatabaseFactory, errorHandler func(err error)) (err error) {
-	ctx, cancel := context.WithCancel(parentCtx)
-	camundaInstance, err := camunda.Get(ctx, config)
+func StartWith(parentCtx context.Context, config configuration.Config, source interfaces.SourceFactory, camunda interfaces.CamundaFactory, database interfaces.DatabaseFactory, errorHandler func(err error)) (handle *Handle, err error) {
+	shutdownTimeout := DefaultShutdownTimeout
+	if config.ShutdownTimeout != "" {
+		shutdownTimeout, err = time.ParseDuration(config.ShutdownTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resourceCtx, stopResources := context.WithCancel(parentCtx)
+	workerCtx, stopWorkers := context.WithCancel(resourceCtx)
+	intakeCtx, stopIntake := context.WithCancel(workerCtx)
+	handle = &Handle{stopIntake: stopIntake, stopWorkers: stopWorkers, stopResources: stopResources, running: &sync.WaitGroup{}, timeout: shutdownTimeout}
+
+	tracer, err := tracing.New(config)
+	if err != nil {
+		stopResources()
+		return nil, err
+	}
+	handle.addCloser(tracer) //closed last, to flush the spans of the shutdown
+	camundaInstance, err := camunda.Get(resourceCtx, config)
+	if err != nil {
+		return nil, handle.abort(err)
+	}
+	handle.addCloser(camundaInstance)
+	databaseInstance, err := database.Get(resourceCtx, config)
+	if err != nil {
+		return nil, handle.abort(err)
+	}
+	handle.addCloser(databaseInstance)
+	m := metrics.New()
+	for _, subsystem := range []interface{}{databaseInstance, camundaInstance} {
+		if provider, ok := subsystem.(interfaces.HealthCheckProvider); ok {
+			provider.RegisterHealthChecks(m.Health)
+		}
+	}
+	if user, ok := camundaInstance.(interfaces.CamundaMetricsUser); ok {
+		user.SetCamundaMetrics(m)
+	}
+	m.Serve(resourceCtx, config.MetricsPort)
+	handle.addCloser(m)
+	adminApi, err := api.Start(resourceCtx, config, databaseInstance, camundaInstance)
 	if err != nil {
-		cancel()
-		return err
+		return nil, handle.abort(err)
 	}
-	databaseInstance, err := database.Get(ctx, config)
+	handle.addCloser(adminApi)
+	ctrl, err := controller.New(workerCtx, config, camundaInstance, databaseInstance, m)
 	if err != nil {
-		cancel()
-		return err
+		return nil, handle.abort(err)
+	}
+	handle.workers = append(handle.workers, ctrl)
+	ctrl.SetDeadLetterReplay(func() (count int, err error) {
+		return source.ReplayDeadLetters(workerCtx, config)
+	})
+	err = ctrl.ResumeIncidentSagas(intakeCtx, handle.running)
+	if err != nil {
+		return nil, handle.abort(err)
+	}
+	var outboxLock interfaces.LeaderLock
+	if provider, ok := camundaInstance.(interfaces.LeaderLockProvider); ok {
+		outboxLock = provider.NewLeaderLock(outbox.LeaderElection)
 	}
-	m := metrics.New().Serve(ctx, config.MetricsPort)
-	ctrl, err := controller.New(ctx, config, camundaInstance, databaseInstance, m)
+	outboxRunning := &sync.WaitGroup{}
+	handle.workers = append(handle.workers, outboxRunning)
+	err = outbox.Start(workerCtx, config, databaseInstance, outboxLock, m, outboxRunning)
 	if err != nil {
-		cancel()
-		return err
+		return nil, handle.abort(err)
 	}
-	err = source.Start(ctx, config, ctrl, errorHandler)
+	err = source.Start(intakeCtx, config, ctrl, interfaces.SourceOptions{Metrics: m, Running: handle.running, Health: m.Health}, errorHandler)
 	if err != nil {
-		cancel()
-		return err
+		return nil, handle.abort(err)
 	}
-	err = camundasource.Start(ctx, config, camundaInstance, ctrl)
+	err = camundasource.Start(intakeCtx, config, camundaInstance, ctrl, m, handle.running)
 	if err != nil {
-		cancel()
-		return err
+		return nil, handle.abort(err)
 	}
 
-	return nil
+	return handle, nil
 }
@@ -25,25 +25,167 @@ type KafkaIncidentsCommand struct {
 	Handler             *OnIncident `json:"handler,omitempty"`
 	ProcessDefinitionId string      `json:"process_definition_id,omitempty"`
 	ProcessInstanceId   string      `json:"process_instance_id,omitempty"`
+	IncidentId          string      `json:"incident_id,omitempty"` //ACK and RESOLVE
+	Actor               string      `json:"actor,omitempty"`       //user that sent ACK or RESOLVE
+	Comment             string      `json:"comment,omitempty"`
 }
 
 type Incident struct {
-	Id                  string    `json:"id" bson:"id"`
-	MsgVersion          int64     `json:"msg_version,omitempty" bson:"msg_version,omitempty"` //from version 3 onward will be set in KafkaIncidentsCommand and be copied to this field
-	ExternalTaskId      string    `json:"external_task_id" bson:"external_task_id"`
-	ProcessInstanceId   string    `json:"process_instance_id" bson:"process_instance_id"`
+	Id                  string                     `json:"id" bson:"id"`
+	MsgVersion          int64                      `json:"msg_version,omitempty" bson:"msg_version,omitempty"` //from version 3 onward will be set in KafkaIncidentsCommand and be copied to this field
+	ExternalTaskId      string                     `json:"external_task_id" bson:"external_task_id"`
+	ProcessInstanceId   string                     `json:"process_instance_id" bson:"process_instance_id"`
+	ProcessDefinitionId string                     `json:"process_definition_id" bson:"process_definition_id"`
+	WorkerId            string                     `json:"worker_id" bson:"worker_id"`
+	ErrorMessage        string                     `json:"error_message" bson:"error_message"`
+	Time                time.Time                  `json:"time" bson:"time"`
+	TenantId            string                     `json:"tenant_id" bson:"tenant_id"`
+	DeploymentName      string                     `json:"deployment_name" bson:"deployment_name"`
+	HandlingMode        string                     `json:"handling_mode,omitempty" bson:"handling_mode,omitempty"` //set if the incident was handled with a mode other than the default stop and restart
+	ActivityId          string                     `json:"activity_id,omitempty" bson:"activity_id,omitempty"`
+	RuleAction          string                     `json:"rule_action,omitempty" bson:"rule_action,omitempty"` //action of the IncidentRule that matched the incident
+	DryRun              bool                       `json:"dry_run,omitempty" bson:"dry_run,omitempty"`
+	DryRunActions       []string                   `json:"dry_run_actions,omitempty" bson:"dry_run_actions,omitempty"` //actions the worker would have taken if not in dry-run mode
+	Status              string                     `json:"status,omitempty" bson:"status,omitempty"`                   //one of IncidentStatus...; incidents without status are open
+	StatusHistory       []IncidentStatusTransition `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	AcknowledgedAt      *time.Time                 `json:"acknowledged_at,omitempty" bson:"acknowledged_at,omitempty"`
+	AcknowledgedBy      string                     `json:"acknowledged_by,omitempty" bson:"acknowledged_by,omitempty"`
+	ResolvedAt          *time.Time                 `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"` //resolved or auto-resolved
+	ResolvedBy          string                     `json:"resolved_by,omitempty" bson:"resolved_by,omitempty"`
+}
+
+const (
+	IncidentStatusOpen         = "open"
+	IncidentStatusAcknowledged = "acknowledged"
+	IncidentStatusResolved     = "resolved"
+	IncidentStatusAutoResolved = "auto_resolved" //resolved by the worker after a successful restart or resume
+)
+
+type IncidentStatusTransition struct {
+	From    string    `json:"from,omitempty" bson:"from,omitempty"`
+	To      string    `json:"to" bson:"to"`
+	Time    time.Time `json:"time" bson:"time"`
+	Actor   string    `json:"actor" bson:"actor"`
+	Comment string    `json:"comment,omitempty" bson:"comment,omitempty"`
+}
+
+type OnIncident struct {
+	ProcessDefinitionId  string                 `json:"process_definition_id" bson:"process_definition_id"`
+	TenantId             string                 `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"` //owner of the handler; set by the admin api for tenant tokens
+	Restart              bool                   `json:"restart" bson:"restart"`
+	Notify               bool                   `json:"notify" bson:"notify"`
+	NotifyImmediately    bool                   `json:"notify_immediately,omitempty" bson:"notify_immediately,omitempty"`       //opt out of notification digests
+	NotificationChannels []string               `json:"notification_channels,omitempty" bson:"notification_channels,omitempty"` //names of configured notification channels; overrides the channels of the tenant
+	Mode                 string                 `json:"mode,omitempty" bson:"mode,omitempty"`                                   //one of OnIncidentMode...; defaults to OnIncidentModeRestart
+	ResumeActivityId     string                 `json:"resume_activity_id,omitempty" bson:"resume_activity_id,omitempty"`       //used with OnIncidentModeResume; if empty, the failed activity is started again
+	RestartVariables     map[string]interface{} `json:"restart_variables,omitempty" bson:"restart_variables,omitempty"`         //start-parameters used on restart; override values copied from the failed instance
+	RestartPolicy        *RestartPolicy         `json:"restart_policy,omitempty" bson:"restart_policy,omitempty"`
+	Rules                []IncidentRule         `json:"rules,omitempty" bson:"rules,omitempty"`                 //checked before the global rules of the worker config
+	RestartState         *RestartState          `json:"restart_state,omitempty" bson:"restart_state,omitempty"` //managed by the worker; reset if the handler is saved again
+}
+
+const (
+	OnIncidentModeRestart = "restart" //stop the failed instance and start a new one
+	OnIncidentModeResume  = "resume"  //cancel the failed activity and start it (or ResumeActivityId) again inside the same instance
+)
+
+const (
+	OnRestartBudgetExhaustedStop    = "stop"    //stop restarting until the window has passed
+	OnRestartBudgetExhaustedNotify  = "notify"  //like stop, but notify the user that the budget is exhausted
+	OnRestartBudgetExhaustedDisable = "disable" //notify the user and disable the handler until it is saved again
+)
+
+const (
+	IncidentActionStop       = "stop"     //stop the instance without restart
+	IncidentActionRestart    = "restart"  //stop and restart the instance, even if the handler does not restart
+	IncidentActionIgnore     = "ignore"   //leave the instance untouched; the incident is only logged
+	IncidentActionNotifyOnly = "notify"   //notify the user and store the incident but leave the instance untouched
+	IncidentActionEscalate   = "escalate" //stop the instance and notify the user and the EscalateTo users
+)
+
+// IncidentRule matches an incident if all set fields match; ErrorMessage and WorkerId are regular expressions
+type IncidentRule struct {
+	ErrorMessage string   `json:"error_message,omitempty" bson:"error_message,omitempty"`
+	WorkerId     string   `json:"worker_id,omitempty" bson:"worker_id,omitempty"`
+	ActivityId   string   `json:"activity_id,omitempty" bson:"activity_id,omitempty"`
+	TenantId     string   `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Action       string   `json:"action" bson:"action"` //one of IncidentAction...
+	EscalateTo   []string `json:"escalate_to,omitempty" bson:"escalate_to,omitempty"`
+}
+
+type RestartPolicy struct {
+	MaxRestarts  int     `json:"max_restarts" bson:"max_restarts"`                       //restarts allowed per window; 0 means unlimited
+	Window       string  `json:"window,omitempty" bson:"window,omitempty"`               //duration string (e.g. "1h"); empty means the budget is never reset
+	InitialDelay string  `json:"initial_delay,omitempty" bson:"initial_delay,omitempty"` //duration string; delay before the first restart in a window
+	MaxDelay     string  `json:"max_delay,omitempty" bson:"max_delay,omitempty"`         //duration string; upper bound of the exponential delay
+	DelayFactor  float64 `json:"delay_factor,omitempty" bson:"delay_factor,omitempty"`   //defaults to 2
+	OnExhausted  string  `json:"on_exhausted,omitempty" bson:"on_exhausted,omitempty"`   //one of OnRestartBudgetExhausted...; defaults to "stop"
+}
+
+type RestartState struct {
+	WindowStart time.Time              `json:"window_start" bson:"window_start"`
+	Count       int                    `json:"count" bson:"count"`
+	LastRestart time.Time              `json:"last_restart" bson:"last_restart"`
+	Disabled    bool                   `json:"disabled" bson:"disabled"`
+	DisabledAt  time.Time              `json:"disabled_at,omitempty" bson:"disabled_at,omitempty"`
+	Exhausted   bool                   `json:"exhausted,omitempty" bson:"exhausted,omitempty"` //the budget of the window is used up; reset with the window
+	Version     int64                  `json:"version" bson:"version"`                         //incremented with every update of the state
+	Decisions   []RestartStateDecision `json:"decisions,omitempty" bson:"decisions,omitempty"` //latest decisions; a retried incident reuses its decision
+}
+
+type RestartStateDecision struct {
+	IncidentId string        `json:"incident_id" bson:"incident_id"`
+	Restart    bool          `json:"restart" bson:"restart"`
+	Delay      time.Duration `json:"delay" bson:"delay"`
+	Exhausted  bool          `json:"exhausted" bson:"exhausted"`
+	Action     string        `json:"action,omitempty" bson:"action,omitempty"`
+}
+
+// DefinitionSuspension records a process-definition suspended by the worker because of an incident storm
+type DefinitionSuspension struct {
 	ProcessDefinitionId string    `json:"process_definition_id" bson:"process_definition_id"`
-	WorkerId            string    `json:"worker_id" bson:"worker_id"`
-	ErrorMessage        string    `json:"error_message" bson:"error_message"`
-	Time                time.Time `json:"time" bson:"time"`
 	TenantId            string    `json:"tenant_id" bson:"tenant_id"`
 	DeploymentName      string    `json:"deployment_name" bson:"deployment_name"`
+	SuspendedAt         time.Time `json:"suspended_at" bson:"suspended_at"`
+	IncidentCount       int       `json:"incident_count" bson:"incident_count"` //incidents within the storm window that triggered the suspension
+	Window              string    `json:"window" bson:"window"`
+	IncidentId          string    `json:"incident_id" bson:"incident_id"` //incident that triggered the suspension; a retry of it repeats the suspension
 }
 
-type OnIncident struct {
-	ProcessDefinitionId string `json:"process_definition_id" bson:"process_definition_id"`
-	Restart             bool   `json:"restart" bson:"restart"`
-	Notify              bool   `json:"notify" bson:"notify"`
+const IncidentHandledEventVersion = 1
+
+const (
+	HandledActionStop    = "stop"
+	HandledActionRestart = "restart"
+	HandledActionResume  = "resume"
+	HandledActionNotify  = "notify"
+	HandledActionDigest  = "digest" //notification added to a digest
+	HandledActionSuspend = "suspend"
+	HandledActionIgnore  = "ignore"
+)
+
+// IncidentHandledEvent is published to KafkaIncidentHandledTopic after an incident has been handled
+type IncidentHandledEvent struct {
+	Version                    int64                   `json:"version"` //IncidentHandledEventVersion
+	Incident                   Incident                `json:"incident"`
+	Actions                    []IncidentHandledAction `json:"actions"`
+	RestartedProcessInstanceId string                  `json:"restarted_process_instance_id,omitempty"`
+	Time                       time.Time               `json:"time"`
+}
+
+type IncidentHandledAction struct {
+	Action string `json:"action"`           //one of HandledAction...
+	Target string `json:"target,omitempty"` //e.g. the notified user or the suspended process-definition
+	Error  string `json:"error,omitempty"`
+}
+
+// OutboxEvent is stored before it is published to kafka, so that it is not lost while kafka is unavailable
+type OutboxEvent struct {
+	Id        string    `json:"id" bson:"id"`
+	Topic     string    `json:"topic" bson:"topic"`
+	Key       string    `json:"key" bson:"key"`
+	Payload   string    `json:"payload" bson:"payload"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 }
 
 type CamundaIncident struct {
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package messages
+
+import "time"
+
+const (
+	SagaStepSave        = "save"         //save the incident
+	SagaStepPublish     = "publish"      //store the IncidentHandledEvent in the outbox
+	SagaStepAutoResolve = "auto_resolve" //set the incident status to IncidentStatusAutoResolved after a successful restart or resume
+	SagaStepSetMode     = "set_mode"     //store the handling mode (Target) of the incident after a successful resume
+)
+
+// IncidentSaga is the stored handling plan of an incident; after a crash, the worker continues at the first unfinished step
+type IncidentSaga struct {
+	Id         string     `json:"id" bson:"id"` //incident id
+	Incident   Incident   `json:"incident" bson:"incident"`
+	Handling   OnIncident `json:"handling" bson:"handling"`
+	Steps      []SagaStep `json:"steps" bson:"steps"`
+	Finished   bool       `json:"finished" bson:"finished"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"` //finished sagas are removed after IncidentSagaRetention
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	Owner      string     `json:"owner,omitempty" bson:"owner,omitempty"`             //replica running the saga
+	LeaseUntil time.Time  `json:"lease_until,omitempty" bson:"lease_until,omitempty"` //other replicas may only claim the saga after this time
+}
+
+type SagaStep struct {
+	Action       string                 `json:"action" bson:"action"`                                 //one of HandledAction... or SagaStep...
+	Target       string                 `json:"target,omitempty" bson:"target,omitempty"`             //notified user, suspended process-definition, restarted process-instance or handling mode
+	Notification *SagaNotification      `json:"notification,omitempty" bson:"notification,omitempty"` //notify and digest steps
+	Variables    map[string]interface{} `json:"variables,omitempty" bson:"variables,omitempty"`       //start variables of restart steps
+	NotBefore    time.Time              `json:"not_before,omitempty" bson:"not_before,omitempty"`     //delayed restart or resume
+	Done         bool                   `json:"done" bson:"done"`
+	DoneAt       time.Time              `json:"done_at,omitempty" bson:"done_at,omitempty"`
+	Error        string                 `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+type SagaNotification struct {
+	UserId   string   `json:"user_id" bson:"user_id"`
+	Title    string   `json:"title" bson:"title"`
+	Message  string   `json:"message" bson:"message"`
+	Topic    string   `json:"topic" bson:"topic"`
+	Channels []string `json:"channels,omitempty" bson:"channels,omitempty"`
+}
@@ -18,21 +18,44 @@ package metrics
 
 import (
 	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/health"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"time"
 )
 
 type Metrics struct {
-	IncidentMessages prometheus.Counter
-	httphandler      http.Handler
+	IncidentMessages             prometheus.Counter
+	SuppressedDuplicateIncidents *prometheus.CounterVec
+	ConsumedMessages             *prometheus.CounterVec
+	MessageHandlingDuration      *prometheus.HistogramVec
+	ConsumerLag                  *prometheus.GaugeVec
+	ConsumerInFlight             *prometheus.GaugeVec
+	MessageParseErrors           *prometheus.CounterVec
+	GetOnIncidentDuration        prometheus.Histogram
+	CamundaRequests              *prometheus.CounterVec
+	CamundaRequestDuration       *prometheus.HistogramVec
+	CamundaActions               *prometheus.CounterVec
+	Notifications                *prometheus.CounterVec
+	DedupCacheLookups            *prometheus.CounterVec
+	TopicMutexWait               *prometheus.HistogramVec
+	CamundaPolls                 *prometheus.CounterVec
+	CamundaPolledIncidents       *prometheus.GaugeVec
+	CamundaPollDuration          *prometheus.HistogramVec
+	Leader                       *prometheus.GaugeVec
+	Health                       *health.Registry
+	httphandler                  http.Handler
+	server                       *http.Server
 }
 
 func New() *Metrics {
 	reg := prometheus.NewRegistry()
 	m := &Metrics{
+		Health: health.New(),
 		httphandler: promhttp.HandlerFor(
 			reg,
 			promhttp.HandlerOpts{
@@ -43,9 +66,99 @@ func New() *Metrics {
 			Name: "incident_worker_incident_messages",
 			Help: "count of incident messages received since startup",
 		}),
+		SuppressedDuplicateIncidents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_suppressed_duplicate_incidents",
+			Help: "count of incidents not handled because an incident with the same dedup key was handled within the dedup window",
+		}, []string{"dedup_key"}),
+		ConsumedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_consumed_messages",
+			Help: "count of kafka messages handled since startup by result (success, dead_letter, dropped, error)",
+		}, []string{"topic", "result"}),
+		MessageHandlingDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "incident_worker_message_handling_seconds",
+			Help:    "duration of kafka message handling including retries",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+		}, []string{"topic"}),
+		ConsumerLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "incident_worker_consumer_lag",
+			Help: "messages of the partition not yet fetched by the consumer",
+		}, []string{"topic", "partition"}),
+		ConsumerInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "incident_worker_consumer_in_flight",
+			Help: "fetched kafka messages that are not yet committed",
+		}, []string{"topic"}),
+		MessageParseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_message_parse_errors",
+			Help: "count of kafka messages that could not be parsed by msg_version (unknown if the version is not readable)",
+		}, []string{"version"}),
+		GetOnIncidentDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "incident_worker_get_on_incident_seconds",
+			Help:    "duration of the on-incident handler lookup in the database",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+		CamundaRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_camunda_requests",
+			Help: "count of camunda requests by shard, method and status code (error for network errors, unavailable if the circuit breaker is open); retries are counted separately",
+		}, []string{"shard", "method", "status"}),
+		CamundaRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "incident_worker_camunda_request_seconds",
+			Help:    "duration of single camunda requests by shard",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 8),
+		}, []string{"shard"}),
+		CamundaActions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_camunda_actions",
+			Help: "count of stop, restart and resume actions by result (success, error, unavailable)",
+		}, []string{"action", "result"}),
+		Notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_notifications",
+			Help: "count of notifications by channel and result (success, error, unknown_channel)",
+		}, []string{"channel", "result"}),
+		DedupCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_dedup_cache_lookups",
+			Help: "count of incident dedup cache lookups by result (hit, miss)",
+		}, []string{"result"}),
+		TopicMutexWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "incident_worker_topic_mutex_wait_seconds",
+			Help:    "time waited for the incident (dedup key) or saga lock",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 5, 10),
+		}, []string{"lock"}),
+		CamundaPolls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "incident_worker_camunda_polls",
+			Help: "count of camunda incident polls by shard and result (success, error)",
+		}, []string{"shard", "result"}),
+		CamundaPolledIncidents: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "incident_worker_camunda_polled_incidents",
+			Help: "incidents found by the last successful poll of the shard",
+		}, []string{"shard"}),
+		CamundaPollDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "incident_worker_camunda_poll_seconds",
+			Help:    "duration of the incident poll of a shard including all pages",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"shard"}),
+		Leader: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "incident_worker_leader",
+			Help: "1 while this replica is the leader of the election, otherwise 0",
+		}, []string{"election"}),
 	}
 
 	reg.MustRegister(m.IncidentMessages)
+	reg.MustRegister(m.SuppressedDuplicateIncidents)
+	reg.MustRegister(m.ConsumedMessages)
+	reg.MustRegister(m.MessageHandlingDuration)
+	reg.MustRegister(m.ConsumerLag)
+	reg.MustRegister(m.ConsumerInFlight)
+	reg.MustRegister(m.MessageParseErrors)
+	reg.MustRegister(m.GetOnIncidentDuration)
+	reg.MustRegister(m.CamundaRequests)
+	reg.MustRegister(m.CamundaRequestDuration)
+	reg.MustRegister(m.CamundaActions)
+	reg.MustRegister(m.Notifications)
+	reg.MustRegister(m.DedupCacheLookups)
+	reg.MustRegister(m.TopicMutexWait)
+	reg.MustRegister(m.CamundaPolls)
+	reg.MustRegister(m.CamundaPolledIncidents)
+	reg.MustRegister(m.CamundaPollDuration)
+	reg.MustRegister(m.Leader)
 
 	return m
 }
@@ -61,10 +174,13 @@ func (this *Metrics) Serve(ctx context.Context, port string) *Metrics {
 	router := http.NewServeMux()
 
 	router.Handle("/metrics", this)
+	router.HandleFunc("/health/live", this.Health.ServeLive)
+	router.HandleFunc("/health/ready", this.Health.ServeReady)
 
 	server := &http.Server{Addr: ":" + port, Handler: router}
+	this.server = server
 	go func() {
-		log.Println("listening on ", server.Addr, "for /metrics")
+		log.Println("listening on ", server.Addr, "for /metrics, /health/live and /health/ready")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			debug.PrintStack()
 			log.Fatal("FATAL:", err)
@@ -77,8 +193,125 @@ func (this *Metrics) Serve(ctx context.Context, port string) *Metrics {
 	return this
 }
 
+// Close shuts the server of Serve down; the server is also shut down when the ctx of Serve is done
+func (this *Metrics) Close() error {
+	if this == nil || this.server == nil {
+		return nil
+	}
+	log.Println("metrics shutdown")
+	return this.server.Shutdown(context.Background())
+}
+
 func (this *Metrics) NotifyIncidentMessage() {
 	if this != nil && this.IncidentMessages != nil {
 		this.IncidentMessages.Inc()
 	}
 }
+
+func (this *Metrics) NotifySuppressedDuplicateIncident(dedupKey string) {
+	if dedupKey == "" {
+		dedupKey = "instance"
+	}
+	if this != nil && this.SuppressedDuplicateIncidents != nil {
+		this.SuppressedDuplicateIncidents.WithLabelValues(dedupKey).Inc()
+	}
+}
+
+func (this *Metrics) NotifyConsumedMessage(topic string, result string, duration time.Duration) {
+	if this != nil && this.ConsumedMessages != nil {
+		this.ConsumedMessages.WithLabelValues(topic, result).Inc()
+	}
+	if this != nil && this.MessageHandlingDuration != nil {
+		this.MessageHandlingDuration.WithLabelValues(topic).Observe(duration.Seconds())
+	}
+}
+
+func (this *Metrics) SetConsumerLag(topic string, partition int, lag int64) {
+	if lag < 0 {
+		lag = 0
+	}
+	if this != nil && this.ConsumerLag != nil {
+		this.ConsumerLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(lag))
+	}
+}
+
+func (this *Metrics) SetConsumerInFlight(topic string, count int) {
+	if this != nil && this.ConsumerInFlight != nil {
+		this.ConsumerInFlight.WithLabelValues(topic).Set(float64(count))
+	}
+}
+
+func (this *Metrics) NotifyMessageParseError(version string) {
+	if this != nil && this.MessageParseErrors != nil {
+		this.MessageParseErrors.WithLabelValues(version).Inc()
+	}
+}
+
+func (this *Metrics) ObserveGetOnIncident(duration time.Duration) {
+	if this != nil && this.GetOnIncidentDuration != nil {
+		this.GetOnIncidentDuration.Observe(duration.Seconds())
+	}
+}
+
+func (this *Metrics) NotifyCamundaRequest(shard string, method string, status string, duration time.Duration) {
+	if this != nil && this.CamundaRequests != nil {
+		this.CamundaRequests.WithLabelValues(shard, method, status).Inc()
+	}
+	if this != nil && this.CamundaRequestDuration != nil && duration > 0 {
+		this.CamundaRequestDuration.WithLabelValues(shard).Observe(duration.Seconds())
+	}
+}
+
+func (this *Metrics) NotifyCamundaAction(action string, result string) {
+	if this != nil && this.CamundaActions != nil {
+		this.CamundaActions.WithLabelValues(action, result).Inc()
+	}
+}
+
+func (this *Metrics) NotifyNotification(channel string, result string) {
+	if this != nil && this.Notifications != nil {
+		this.Notifications.WithLabelValues(channel, result).Inc()
+	}
+}
+
+func (this *Metrics) NotifyDedupCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	if this != nil && this.DedupCacheLookups != nil {
+		this.DedupCacheLookups.WithLabelValues(result).Inc()
+	}
+}
+
+func (this *Metrics) ObserveTopicMutexWait(lock string, duration time.Duration) {
+	if this != nil && this.TopicMutexWait != nil {
+		this.TopicMutexWait.WithLabelValues(lock).Observe(duration.Seconds())
+	}
+}
+
+func (this *Metrics) NotifyCamundaPoll(shard string, incidents int, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	if this != nil && this.CamundaPolls != nil {
+		this.CamundaPolls.WithLabelValues(shard, result).Inc()
+	}
+	if this != nil && this.CamundaPolledIncidents != nil && err == nil {
+		this.CamundaPolledIncidents.WithLabelValues(shard).Set(float64(incidents))
+	}
+	if this != nil && this.CamundaPollDuration != nil {
+		this.CamundaPollDuration.WithLabelValues(shard).Observe(duration.Seconds())
+	}
+}
+
+func (this *Metrics) SetLeader(election string, leader bool) {
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	if this != nil && this.Leader != nil {
+		this.Leader.WithLabelValues(election).Set(value)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package notification
+
+import (
+	"fmt"
+	developerNotifications "github.com/SENERGY-Platform/developer-notifications/pkg/client"
+	"log"
+)
+
+// DeveloperNotifier forwards user notifications to the developer-notifications service
+type DeveloperNotifier struct {
+	client developerNotifications.Client
+}
+
+func NewDeveloperNotifier(url string) *DeveloperNotifier {
+	return NewDeveloperNotifierWithClient(developerNotifications.New(url))
+}
+
+func NewDeveloperNotifierWithClient(client developerNotifications.Client) *DeveloperNotifier {
+	return &DeveloperNotifier{client: client}
+}
+
+// Notify sends asynchronously because the developer-notifications client has no timeout
+func (this *DeveloperNotifier) Notify(msg Message) error {
+	go func() {
+		err := this.client.SendMessage(developerNotifications.Message{
+			Sender: "github.com/SENERGY-Platform/process-incident-worker",
+			Title:  "Process-Incident-User-Notification",
+			Tags:   []string{"process-incident", "user-notification", msg.UserId},
+			Body:   fmt.Sprintf("Notification For %v\nTitle: %v\nMessage: %v\n", msg.UserId, msg.Title, msg.Message),
+		})
+		if err != nil {
+			log.Println("ERROR: unable to send developer-notification", err)
+		}
+	}()
+	return nil
+}
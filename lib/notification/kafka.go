@@ -0,0 +1,60 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaNotifier publishes the Message as json; the user id is used as key
+type KafkaNotifier struct {
+	writer MessageWriter
+}
+
+// NewKafkaNotifier uses writer (e.g. with the TLS/SASL transport of the worker) and closes it when ctx is done
+func NewKafkaNotifier(ctx context.Context, writer *kafka.Writer) *KafkaNotifier {
+	writer.WriteTimeout = NotifierTimeout
+	go func() {
+		<-ctx.Done()
+		_ = writer.Close()
+	}()
+	return NewKafkaNotifierWithWriter(writer)
+}
+
+func NewKafkaNotifierWithWriter(writer MessageWriter) *KafkaNotifier {
+	return &KafkaNotifier{writer: writer}
+}
+
+func (this *KafkaNotifier) Notify(msg Message) error {
+	value, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), NotifierTimeout)
+	defer cancel()
+	return this.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(msg.UserId),
+		Value: value,
+		Time:  time.Now(),
+	})
+}
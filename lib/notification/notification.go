@@ -63,6 +63,8 @@ type Message struct {
 	Title   string `json:"title" bson:"title"`
 	Message string `json:"message" bson:"message"`
 	Topic   string `json:"topic" bson:"topic"`
+
+	Channels []string `json:"-" bson:"-"` //names of the channels the message is sent to; empty for the channels of the tenant or the default channels
 }
 
 const Topic = "incident"
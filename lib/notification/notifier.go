@@ -0,0 +1,79 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	ChannelTypePlatform   = "platform"   //platform notifier at Url
+	ChannelTypeDeveloper  = "developer"  //developer-notifications at Url
+	ChannelTypeWebhook    = "webhook"    //POST of the Message as json to Url
+	ChannelTypeSlack      = "slack"      //slack incoming webhook at Url
+	ChannelTypeMattermost = "mattermost" //mattermost incoming webhook at Url
+	ChannelTypeSmtp       = "smtp"       //email over the smtp server at Url (host:port)
+	ChannelTypeKafka      = "kafka"      //Message as json to Topic; key is the user id
+)
+
+// names of the channels created from notification_url and developer_notification_url
+const (
+	ChannelPlatform  = "platform"
+	ChannelDeveloper = "developer"
+)
+
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+type ChannelConfig struct {
+	Type       string              `json:"type"`
+	Url        string              `json:"url"`
+	Headers    map[string]string   `json:"headers,omitempty"`    //webhook
+	Username   string              `json:"username,omitempty"`   //smtp
+	Password   string              `json:"password,omitempty"`   //smtp
+	From       string              `json:"from,omitempty"`       //smtp
+	To         []string            `json:"to,omitempty"`         //smtp; used if the user has no entry in Recipients
+	Recipients map[string][]string `json:"recipients,omitempty"` //smtp; user -> email addresses
+	Topic      string              `json:"topic,omitempty"`      //kafka
+}
+
+const NotifierTimeout = 5 * time.Second
+
+var httpClient = &http.Client{Timeout: NotifierTimeout}
+
+// NewNotifier creates the http and smtp based channels; kafka channels are created with NewKafkaNotifier
+func NewNotifier(config ChannelConfig) (Notifier, error) {
+	if config.Url == "" {
+		return nil, errors.New("missing url for notification channel of type " + config.Type)
+	}
+	switch config.Type {
+	case ChannelTypePlatform:
+		return &PlatformNotifier{url: config.Url}, nil
+	case ChannelTypeDeveloper:
+		return NewDeveloperNotifier(config.Url), nil
+	case ChannelTypeWebhook:
+		return &WebhookNotifier{url: config.Url, headers: config.Headers}, nil
+	case ChannelTypeSlack, ChannelTypeMattermost:
+		return &SlackNotifier{url: config.Url}, nil
+	case ChannelTypeSmtp:
+		return NewSmtpNotifier(config)
+	default:
+		return nil, errors.New("unknown notification channel type: " + config.Type)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	developerNotifications "github.com/SENERGY-Platform/developer-notifications/pkg/client"
+	"github.com/segmentio/kafka-go"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMessage = Message{UserId: "user", Title: "title", Message: "line1\nline2", Topic: Topic}
+
+func TestWebhookNotifiers(t *testing.T) {
+	received := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body := map[string]interface{}{}
+		err := json.NewDecoder(request.Body).Decode(&body)
+		if err != nil {
+			t.Error(err)
+		}
+		b, _ := json.Marshal(body)
+		received[request.URL.Path] = string(b) + request.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	for _, channel := range []ChannelConfig{
+		{Type: ChannelTypePlatform, Url: server.URL + "/platform"},
+		{Type: ChannelTypeWebhook, Url: server.URL + "/webhook", Headers: map[string]string{"Authorization": "token"}},
+		{Type: ChannelTypeSlack, Url: server.URL + "/slack"},
+	} {
+		notifier, err := NewNotifier(channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = notifier.Notify(testMessage)
+		if err != nil {
+			t.Fatal(channel.Type, err)
+		}
+	}
+
+	expected := map[string]string{
+		"/platform/notifications": `{"message":"line1\nline2","title":"title","topic":"incident","userId":"user"}`,
+		"/webhook":                `{"message":"line1\nline2","title":"title","topic":"incident","userId":"user"}token`,
+		"/slack":                  `{"text":"*title*\nline1\nline2"}`,
+	}
+	for path, body := range expected {
+		if received[path] != body {
+			t.Errorf("%v: %#v", path, received[path])
+		}
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	notifier, err := NewNotifier(ChannelConfig{Type: ChannelTypeMattermost, Url: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = notifier.Notify(testMessage); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestSmtpNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	mails := make(chan string, 1)
+	go serveTestSmtp(listener, mails)
+
+	notifier, err := NewNotifier(ChannelConfig{
+		Type:       ChannelTypeSmtp,
+		Url:        listener.Addr().String(),
+		From:       "worker@example.com",
+		Recipients: map[string][]string{"user": {"user@example.com"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = notifier.Notify(testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case mail := <-mails:
+		for _, expected := range []string{"RCPT TO:<user@example.com>", "Subject: title", "line1\r\nline2"} {
+			if !strings.Contains(mail, expected) {
+				t.Errorf("missing %#v in %#v", expected, mail)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timeout")
+	}
+
+	//no recipient for user
+	err = notifier.Notify(Message{UserId: "unknown", Title: "title"})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// serveTestSmtp handles one smtp session and sends the received commands and data to mails
+func serveTestSmtp(listener net.Listener, mails chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	write := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	session := strings.Builder{}
+	write("220 localhost")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		session.WriteString(line)
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			write("250 localhost")
+		case command == "DATA":
+			write("354 go ahead")
+			for {
+				line, err = reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				session.WriteString(line)
+			}
+			write("250 ok")
+		case command == "QUIT":
+			write("221 bye")
+			mails <- session.String()
+			return
+		default:
+			write("250 ok")
+		}
+	}
+}
+
+type testWriter struct {
+	messages []kafka.Message
+}
+
+func (this *testWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	this.messages = append(this.messages, msgs...)
+	return nil
+}
+
+func TestKafkaNotifier(t *testing.T) {
+	writer := &testWriter{}
+	err := NewKafkaNotifierWithWriter(writer).Notify(testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.messages) != 1 || string(writer.messages[0].Key) != "user" {
+		t.Fatalf("%#v", writer.messages)
+	}
+	msg := Message{}
+	err = json.Unmarshal(writer.messages[0].Value, &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msg, testMessage) {
+		t.Errorf("%#v", msg)
+	}
+}
+
+func TestDeveloperNotifier(t *testing.T) {
+	received := make(chan developerNotifications.Message, 1)
+	client := developerNotifications.NewTestClient(developerNotifications.TestClientConfig{SendMessageHook: func(message developerNotifications.Message) error {
+		received <- message
+		return nil
+	}})
+	err := NewDeveloperNotifierWithClient(client).Notify(testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if !strings.Contains(msg.Body, "Title: title") {
+			t.Errorf("%#v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timeout")
+	}
+}
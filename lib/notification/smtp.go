@@ -0,0 +1,66 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package notification
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SmtpNotifier struct {
+	addr       string
+	auth       smtp.Auth
+	from       string
+	to         []string
+	recipients map[string][]string
+}
+
+func NewSmtpNotifier(config ChannelConfig) (*SmtpNotifier, error) {
+	host, _, err := net.SplitHostPort(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	if config.From == "" {
+		return nil, errors.New("missing from address for smtp notification channel")
+	}
+	result := &SmtpNotifier{addr: config.Url, from: config.From, to: config.To, recipients: config.Recipients}
+	if config.Username != "" {
+		result.auth = smtp.PlainAuth("", config.Username, config.Password, host)
+	}
+	return result, nil
+}
+
+func (this *SmtpNotifier) Notify(msg Message) error {
+	to, ok := this.recipients[msg.UserId]
+	if !ok {
+		to = this.to
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	body := strings.Builder{}
+	body.WriteString("From: " + this.from + "\r\n")
+	body.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	body.WriteString("Subject: " + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Title) + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Message, "\n", "\r\n"))
+	body.WriteString("\r\n")
+	return smtp.SendMail(this.addr, this.auth, this.from, to, []byte(body.String()))
+}
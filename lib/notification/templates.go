@@ -0,0 +1,254 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const DefaultLocale = "en"
+
+const (
+	TemplateIncident               = "incident"
+	TemplateIncidentEscalated      = "incident_escalated"
+	TemplateEscalation             = "escalation" //sent to the escalate_to users of a rule
+	TemplateRestartBudgetExhausted = "restart_budget_exhausted"
+	TemplateRestartError           = "restart_error"
+	TemplateResumeError            = "resume_error"
+	TemplateStormSuspension        = "storm_suspension"
+	TemplateDigest                 = "digest"
+)
+
+type Template struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+type TemplateData struct {
+	Incident        messages.Incident
+	DeploymentName  string
+	Actions         []string //actions of the worker, e.g. "stop", "restart", "resume"
+	Restart         bool
+	Resume          bool
+	HandlerDisabled bool
+	Error           string
+	Count           int
+	Window          string
+	Errors          []DigestError
+	MoreErrors      int
+	Instances       []string
+	MoreInstances   int
+	Links           map[string]string //rendered deep-link templates by name
+}
+
+type DigestError struct {
+	Message string
+	Count   int
+}
+
+var DefaultTemplates = map[string]Template{
+	TemplateIncident: {
+		Title:   "Process-Incident in {{.DeploymentName}}",
+		Message: "{{.Incident.ErrorMessage}}{{if .Resume}}\n\nprocess will be resumed{{else if .Restart}}\n\nprocess will be restarted{{end}}",
+	},
+	TemplateIncidentEscalated: {
+		Title:   "ESCALATED: Process-Incident in {{.DeploymentName}}",
+		Message: "{{.Incident.ErrorMessage}}",
+	},
+	TemplateEscalation: {
+		Title:   "ESCALATED: Process-Incident in {{.DeploymentName}}",
+		Message: "User: {{.Incident.TenantId}}\nProcess-Instance: {{.Incident.ProcessInstanceId}}\n\n{{.Incident.ErrorMessage}}",
+	},
+	TemplateRestartBudgetExhausted: {
+		Title:   "Restart-Budget exhausted for {{.DeploymentName}}",
+		Message: "process will not be restarted after incident: {{.Incident.ErrorMessage}}{{if .HandlerDisabled}}\n\nthe incident handler has been disabled; save it again to re-enable restarts{{end}}",
+	},
+	TemplateRestartError: {
+		Title:   "ERROR: unable to restart process after incident in: {{.DeploymentName}}",
+		Message: "Restart-Error: {{.Error}} \n\n Incident: {{.Incident.ErrorMessage}} \n",
+	},
+	TemplateResumeError: {
+		Title:   "ERROR: unable to resume process after incident in: {{.DeploymentName}}",
+		Message: "Resume-Error: {{.Error}} \n\n Incident: {{.Incident.ErrorMessage}} \n",
+	},
+	TemplateStormSuspension: {
+		Title:   "Process suspended after incident storm: {{.DeploymentName}}",
+		Message: "{{.Count}} incidents within {{.Window}}; no new instances can be started until the suspension is lifted\n\nlast incident: {{.Incident.ErrorMessage}}",
+	},
+	TemplateDigest: {
+		Title: "{{.Count}} Process-Incidents in {{.DeploymentName}}",
+		Message: "{{.Count}} incidents within {{.Window}}\n\nerrors:\n" +
+			"{{range .Errors}}- ({{.Count}}x) {{.Message}}\n{{end}}{{if .MoreErrors}}- ... ({{.MoreErrors}} more)\n{{end}}" +
+			"\nprocess-instances:\n{{join .Instances \", \"}}{{if .MoreInstances}}, ... ({{.MoreInstances}} more){{end}}",
+	},
+}
+
+var templateFunctions = template.FuncMap{"join": strings.Join}
+
+type parsedTemplate struct {
+	title   *template.Template
+	message *template.Template
+}
+
+// Templates renders notifications in the locale of the receiving user.
+// lookup order: locale of the user, default locale, DefaultLocale, DefaultTemplates
+type Templates struct {
+	locales       map[string]map[string]parsedTemplate
+	userLocales   map[string]string
+	defaultLocale string
+	links         map[string]*template.Template
+}
+
+type TemplatesConfig struct {
+	Templates     map[string]map[string]Template //locale -> template name -> template
+	Dir           string                         //optional; every <locale>.json file contains a template name -> template map
+	UserLocales   map[string]string              //user -> locale
+	DefaultLocale string
+	Links         map[string]string //link name -> url template; rendered with the TemplateData and available as .Links.<name>
+}
+
+func NewTemplates(config TemplatesConfig) (result *Templates, err error) {
+	result = &Templates{
+		locales:       map[string]map[string]parsedTemplate{},
+		userLocales:   config.UserLocales,
+		defaultLocale: config.DefaultLocale,
+		links:         map[string]*template.Template{},
+	}
+	if result.defaultLocale == "" {
+		result.defaultLocale = DefaultLocale
+	}
+	err = result.add(DefaultLocale, DefaultTemplates)
+	if err != nil {
+		return nil, err
+	}
+	if config.Dir != "" {
+		files, err := filepath.Glob(filepath.Join(config.Dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			templates := map[string]Template{}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			err = json.Unmarshal(content, &templates)
+			if err != nil {
+				return nil, errors.New("unable to parse notification templates in " + file + ": " + err.Error())
+			}
+			err = result.add(strings.TrimSuffix(filepath.Base(file), ".json"), templates)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for locale, templates := range config.Templates {
+		err = result.add(locale, templates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, link := range config.Links {
+		result.links[name], err = template.New(name).Funcs(templateFunctions).Parse(link)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (this *Templates) add(locale string, templates map[string]Template) (err error) {
+	if this.locales[locale] == nil {
+		this.locales[locale] = map[string]parsedTemplate{}
+	}
+	for name, t := range templates {
+		parsed := parsedTemplate{}
+		parsed.title, err = template.New(locale + "." + name + ".title").Funcs(templateFunctions).Parse(t.Title)
+		if err != nil {
+			return err
+		}
+		parsed.message, err = template.New(locale + "." + name + ".message").Funcs(templateFunctions).Parse(t.Message)
+		if err != nil {
+			return err
+		}
+		this.locales[locale][name] = parsed
+	}
+	return nil
+}
+
+func (this *Templates) lookup(userId string, name string) (result parsedTemplate, err error) {
+	for _, locale := range []string{this.userLocales[userId], this.defaultLocale, DefaultLocale} {
+		if result, ok := this.locales[locale][name]; ok {
+			return result, nil
+		}
+	}
+	return result, errors.New("unknown notification template: " + name)
+}
+
+// Render creates the notification message for the user; if the localized template fails,
+// the message is rendered with the DefaultTemplates
+func (this *Templates) Render(userId string, name string, data TemplateData) (msg Message, err error) {
+	data.Links = map[string]string{}
+	for linkName, link := range this.links {
+		buf := strings.Builder{}
+		if err = link.Execute(&buf, data); err != nil {
+			return msg, err
+		}
+		data.Links[linkName] = buf.String()
+	}
+	t, err := this.lookup(userId, name)
+	if err != nil {
+		return msg, err
+	}
+	msg, err = render(t, userId, data)
+	if err != nil {
+		fallback, ok := DefaultTemplates[name]
+		if !ok {
+			return msg, err
+		}
+		parsed := parsedTemplate{}
+		parsed.title = template.Must(template.New(name).Funcs(templateFunctions).Parse(fallback.Title))
+		parsed.message = template.Must(template.New(name).Funcs(templateFunctions).Parse(fallback.Message))
+		return render(parsed, userId, data)
+	}
+	return msg, nil
+}
+
+func render(t parsedTemplate, userId string, data TemplateData) (msg Message, err error) {
+	title := strings.Builder{}
+	err = t.title.Execute(&title, data)
+	if err != nil {
+		return msg, err
+	}
+	message := strings.Builder{}
+	err = t.message.Execute(&message, data)
+	if err != nil {
+		return msg, err
+	}
+	return Message{
+		UserId:  userId,
+		Title:   title.String(),
+		Message: message.String(),
+		Topic:   Topic,
+	}, nil
+}
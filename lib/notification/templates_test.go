@@ -0,0 +1,61 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notification
+
+import (
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"testing"
+)
+
+func TestTemplatesRender(t *testing.T) {
+	templates, err := NewTemplates(TemplatesConfig{
+		Templates: map[string]map[string]Template{
+			"de": {TemplateIncident: {Title: "Prozess-Incident in {{.DeploymentName}}", Message: "{{.Incident.ErrorMessage}}\n{{.Links.process}}"}},
+		},
+		UserLocales: map[string]string{"user_de": "de"},
+		Links:       map[string]string{"process": "https://example.com/processes/{{.Incident.ProcessDefinitionId}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := TemplateData{Incident: messages.Incident{ErrorMessage: "error", ProcessDefinitionId: "d1"}, DeploymentName: "name", Restart: true}
+
+	msg, err := templates.Render("user_de", TemplateIncident, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Title != "Prozess-Incident in name" || msg.Message != "error\nhttps://example.com/processes/d1" || msg.UserId != "user_de" {
+		t.Errorf("%#v", msg)
+	}
+
+	msg, err = templates.Render("user_en", TemplateIncident, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Title != "Process-Incident in name" || msg.Message != "error\n\nprocess will be restarted" {
+		t.Errorf("%#v", msg)
+	}
+
+	//missing localized template falls back to en
+	msg, err = templates.Render("user_de", TemplateRestartError, TemplateData{Incident: data.Incident, DeploymentName: "name", Error: "err"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Title != "ERROR: unable to restart process after incident in: name" {
+		t.Errorf("%#v", msg)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type PlatformNotifier struct {
+	url string
+}
+
+func (this *PlatformNotifier) Notify(msg Message) error {
+	return Send(this.url, msg)
+}
+
+// WebhookNotifier posts the Message as json
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+func (this *WebhookNotifier) Notify(msg Message) error {
+	return postJson(this.url, this.headers, msg)
+}
+
+// SlackNotifier posts to slack or mattermost incoming webhooks
+type SlackNotifier struct {
+	url string
+}
+
+type SlackMessage struct {
+	Text string `json:"text"`
+}
+
+func (this *SlackNotifier) Notify(msg Message) error {
+	return postJson(this.url, nil, SlackMessage{Text: "*" + msg.Title + "*\n" + msg.Message})
+}
+
+func postJson(url string, headers map[string]string, body interface{}) error {
+	b := new(bytes.Buffer)
+	err := json.NewEncoder(b).Encode(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respMsg, _ := io.ReadAll(resp.Body)
+		return errors.New("unexpected response status from " + url + ": " + resp.Status + " " + string(respMsg))
+	}
+	return nil
+}
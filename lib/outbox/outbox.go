@@ -0,0 +1,125 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbox
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/leader"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/source/util"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultPublishInterval = time.Second
+const BatchSize = 100
+
+// LeaderElection is the name of the lock held by the publishing replica
+const LeaderElection = "process-incident-worker:outbox-publisher"
+
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Start publishes the events of the outbox collection to kafka until ctx is done;
+// events are deleted after they are written, so they may be published more than once.
+// if lock is set, only the replica holding it publishes; the publisher is tracked in running
+func Start(ctx context.Context, config configuration.Config, db interfaces.Database, lock interfaces.LeaderLock, metrics leader.Metrics, running *sync.WaitGroup) error {
+	if config.KafkaIncidentHandledTopic == "" || config.KafkaIncidentHandledTopic == "-" {
+		return nil
+	}
+	interval := DefaultPublishInterval
+	var err error
+	if config.OutboxPublishInterval != "" {
+		interval, err = time.ParseDuration(config.OutboxPublishInterval)
+		if err != nil {
+			return err
+		}
+	}
+	connection, err := util.NewKafkaConnection(config)
+	if err != nil {
+		return err
+	}
+	err = util.InitTopic(connection, util.NewTopicSettings(config), config.KafkaIncidentHandledTopic)
+	if err != nil {
+		return err
+	}
+	writer := connection.NewWriter("")
+	loop := func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := Publish(ctx, db, writer)
+				if err != nil {
+					log.Println("WARNING: unable to publish outbox events", err)
+				}
+			}
+		}
+	}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		defer writer.Close()
+		if lock == nil {
+			log.Println("WARNING: no leader lock available, outbox events are published by every replica")
+			loop(ctx)
+			return
+		}
+		leader.Run(ctx, LeaderElection, lock, leader.DefaultInterval, metrics, loop)
+	}()
+	return nil
+}
+
+// Publish writes the stored events in order of their creation and stops at the first error
+func Publish(ctx context.Context, db interfaces.Database, writer MessageWriter) error {
+	for {
+		events, err := db.ListOutboxEvents(BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			err = writer.WriteMessages(ctx, toKafkaMessage(event))
+			if err != nil {
+				return err
+			}
+			err = db.DeleteOutboxEvent(event.Id)
+			if err != nil {
+				return err
+			}
+		}
+		if len(events) < BatchSize {
+			return nil
+		}
+	}
+}
+
+func toKafkaMessage(event messages.OutboxEvent) kafka.Message {
+	return kafka.Message{
+		Topic: event.Topic,
+		Key:   []byte(event.Key),
+		Value: []byte(event.Payload),
+		Time:  event.CreatedAt,
+	}
+}
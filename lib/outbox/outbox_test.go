@@ -0,0 +1,93 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/messages"
+	"github.com/segmentio/kafka-go"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testDb struct {
+	interfaces.Database
+	events []messages.OutboxEvent
+}
+
+func (this *testDb) ListOutboxEvents(limit int64) (events []messages.OutboxEvent, err error) {
+	if int64(len(this.events)) < limit {
+		return append(events, this.events...), nil
+	}
+	return append(events, this.events[:limit]...), nil
+}
+
+func (this *testDb) DeleteOutboxEvent(id string) error {
+	for i, event := range this.events {
+		if event.Id == id {
+			this.events = append(this.events[:i], this.events[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+type testWriter struct {
+	messages []kafka.Message
+	failAt   int
+}
+
+func (this *testWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if this.failAt > 0 && len(this.messages)+len(msgs) >= this.failAt {
+		return errors.New("kafka unavailable")
+	}
+	this.messages = append(this.messages, msgs...)
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	db := &testDb{}
+	for i := 0; i < BatchSize+10; i++ {
+		db.events = append(db.events, messages.OutboxEvent{Id: strconv.Itoa(i), Topic: "topic", Key: "key", Payload: strconv.Itoa(i), CreatedAt: time.Now()})
+	}
+
+	writer := &testWriter{failAt: 6}
+	err := Publish(context.Background(), db, writer)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if len(writer.messages) != 5 || len(db.events) != BatchSize+5 {
+		t.Fatal(len(writer.messages), len(db.events))
+	}
+
+	writer.failAt = 0
+	err = Publish(context.Background(), db, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.events) != 0 || len(writer.messages) != BatchSize+10 {
+		t.Fatal(len(writer.messages), len(db.events))
+	}
+	for i, msg := range writer.messages {
+		if string(msg.Value) != strconv.Itoa(i) || msg.Topic != "topic" || string(msg.Key) != "key" {
+			t.Errorf("%v: %#v", i, msg)
+		}
+	}
+}
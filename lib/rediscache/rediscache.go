@@ -0,0 +1,96 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/service-commons/pkg/cache/cacheerrors"
+	"github.com/SENERGY-Platform/service-commons/pkg/cache/interfaces"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Cache implements the service-commons CacheImpl interface with redis, so that it can be used as shared l2 cache
+type Cache struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+var ErrNotFound = cacheerrors.ErrNotFound
+
+func New(redisUrl string, timeout time.Duration) (*Cache, error) {
+	options, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{client: redis.NewClient(options), timeout: timeout}, nil
+}
+
+func NewProvider(redisUrl string, timeout time.Duration) func() (interfaces.CacheImpl, error) {
+	return func() (interfaces.CacheImpl, error) {
+		return New(redisUrl, timeout)
+	}
+}
+
+func (this *Cache) Get(key string) (value interface{}, resultType interfaces.ResultType, err error) {
+	value, resultType, _, err = this.GetWithExpiration(key)
+	return value, resultType, err
+}
+
+func (this *Cache) GetWithExpiration(key string) (value interface{}, resultType interfaces.ResultType, exp time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	pipe := this.client.TxPipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	_, err = pipe.Exec(ctx)
+	if errors.Is(err, redis.Nil) {
+		return value, resultType, exp, ErrNotFound
+	}
+	if err != nil {
+		return value, resultType, exp, err
+	}
+	exp = ttlCmd.Val()
+	return []byte(getCmd.Val()), interfaces.JsonByteArray, exp, nil
+}
+
+func (this *Cache) Set(key string, value interface{}, exp time.Duration) (err error) {
+	temp, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	return this.client.Set(ctx, key, temp, exp).Err()
+}
+
+func (this *Cache) Remove(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	return this.client.Del(ctx, key).Err()
+}
+
+// Reset is not supported because the redis db may be shared with other services
+func (this *Cache) Reset() error {
+	return errors.New("reset of redis cache not supported")
+}
+
+func (this *Cache) Close() (err error) {
+	return this.client.Close()
+}
@@ -21,20 +21,43 @@ import (
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/source/consumer/listener"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/source/util"
 	"log"
+	"time"
 )
 
-func Start(ctx context.Context, config configuration.Config, control interfaces.Controller, runtimeErrorHandler func(err error)) (err error) {
+func Start(ctx context.Context, config configuration.Config, control interfaces.Controller, sourceOptions interfaces.SourceOptions, runtimeErrorHandler func(err error)) (err error) {
+	retryTimeout := DefaultRetryTimeout
+	if config.KafkaMessageRetryTimeout != "" {
+		retryTimeout, err = time.ParseDuration(config.KafkaMessageRetryTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	deadLetterTopic := getDeadLetterTopic(config)
+	connection, err := util.NewKafkaConnection(config)
+	if err != nil {
+		return err
+	}
 	for _, factory := range listener.Factories {
-		topic, handler, err := factory(config, control)
+		topic, handler, orderingKey, err := factory(config, control)
 		if err != nil {
 			return err
 		}
-		err = RunConsumer(ctx, config.KafkaUrl, config.KafkaConsumerGroup, topic, config.Debug, config.TopicConfigMap, func(topic string, msg []byte) error {
+		options := Options{
+			DeadLetterTopic: deadLetterTopic,
+			RetryTimeout:    retryTimeout,
+			Workers:         int(config.KafkaConsumerWorkers),
+			OrderingKey:     orderingKey,
+			Metrics:         sourceOptions.Metrics,
+			Running:         sourceOptions.Running,
+			Health:          sourceOptions.Health,
+		}
+		err = RunConsumer(ctx, connection, config.KafkaConsumerGroup, topic, config.Debug, util.NewTopicSettings(config), options, func(ctx context.Context, topic string, msg []byte) error {
 			if config.Debug {
 				log.Println("DEBUG: consume", topic, string(msg))
 			}
-			return handler(msg)
+			return handler(ctx, msg)
 		}, runtimeErrorHandler)
 		if err != nil {
 			return err
@@ -0,0 +1,213 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package consumer
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/source/util"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headers of dead-letter messages
+const (
+	HeaderDeadLetterError    = "x-dead-letter-error"
+	HeaderDeadLetterReason   = "x-dead-letter-reason" //DeadLetterReason...
+	HeaderDeadLetterTime     = "x-dead-letter-time"
+	HeaderOriginalTopic      = "x-original-topic"
+	HeaderOriginalPartition  = "x-original-partition"
+	HeaderOriginalOffset     = "x-original-offset"
+	HeaderOriginalGroup      = "x-original-consumer-group"
+	HeaderDeadLetterReplayed = "x-dead-letter-replayed" //set on messages replayed to the original topic; the value identifies the replay
+)
+
+const headerDeadLetterPrefix = "x-dead-letter-"
+const headerOriginalPrefix = "x-original-"
+
+const (
+	DeadLetterReasonInvalid = "invalid" //unparseable message
+	DeadLetterReasonFailing = "failing" //handling failed longer than the retry timeout
+)
+
+const DeadLetterReplayGroupSuffix = "-dead-letter-replay"
+const DeadLetterReplayIdleTime = 5 * time.Second
+
+func getDeadLetterTopic(config configuration.Config) string {
+	if config.KafkaDeadLetterTopic == "-" {
+		return ""
+	}
+	return config.KafkaDeadLetterTopic
+}
+
+// createDeadLetter copies key, value and headers of msg and adds the error and origin as headers.
+// the HeaderDeadLetterReplayed header of a replayed message is kept, so that the replay does not replay it again
+func createDeadLetter(msg kafka.Message, groupId string, err error) kafka.Message {
+	reason := DeadLetterReasonFailing
+	if errors.Is(err, interfaces.ErrInvalidMessage) {
+		reason = DeadLetterReasonInvalid
+	}
+	headers := []kafka.Header{}
+	for _, header := range msg.Headers {
+		if header.Key == HeaderDeadLetterReplayed || (!strings.HasPrefix(header.Key, headerDeadLetterPrefix) && !strings.HasPrefix(header.Key, headerOriginalPrefix)) {
+			headers = append(headers, header)
+		}
+	}
+	headers = append(headers,
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(err.Error())},
+		kafka.Header{Key: HeaderDeadLetterReason, Value: []byte(reason)},
+		kafka.Header{Key: HeaderDeadLetterTime, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: HeaderOriginalGroup, Value: []byte(groupId)},
+	)
+	return kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers}
+}
+
+// createReplay restores the message of a dead-letter; the target is the original topic or defaultTopic
+func createReplay(deadLetter kafka.Message, defaultTopic string, replayId string) kafka.Message {
+	topic := defaultTopic
+	headers := []kafka.Header{}
+	for _, header := range deadLetter.Headers {
+		if header.Key == HeaderOriginalTopic && len(header.Value) > 0 {
+			topic = string(header.Value)
+		}
+		if !strings.HasPrefix(header.Key, headerDeadLetterPrefix) && !strings.HasPrefix(header.Key, headerOriginalPrefix) {
+			headers = append(headers, header)
+		}
+	}
+	headers = append(headers, kafka.Header{Key: HeaderDeadLetterReplayed, Value: []byte(replayId)})
+	return kafka.Message{Topic: topic, Key: deadLetter.Key, Value: deadLetter.Value, Headers: headers}
+}
+
+// isReplayedBy checks if the dead-letter has been replayed by the replay and failed again
+func isReplayedBy(deadLetter kafka.Message, replayId string) bool {
+	for _, header := range deadLetter.Headers {
+		if header.Key == HeaderDeadLetterReplayed && string(header.Value) == replayId {
+			return true
+		}
+	}
+	return false
+}
+
+// getEndOffsets returns the offset after the last message of every partition of the topic
+func getEndOffsets(ctx context.Context, connection util.KafkaConnection, topic string) (map[int]int64, error) {
+	client := connection.NewClient()
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.Topics) != 1 || metadata.Topics[0].Error != nil {
+		return nil, errors.New("topic " + topic + " does not exist")
+	}
+	requests := []kafka.OffsetRequest{}
+	for _, partition := range metadata.Topics[0].Partitions {
+		requests = append(requests, kafka.LastOffsetOf(partition.ID))
+	}
+	resp, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+	result := map[int]int64{}
+	for _, partition := range resp.Topics[topic] {
+		if partition.Error != nil {
+			return nil, partition.Error
+		}
+		result[partition.Partition] = partition.LastOffset
+	}
+	return result, nil
+}
+
+// ReplayDeadLetters moves the messages of the dead-letter topic back to their original topic.
+// the replay uses its own consumer group, so that every dead-letter is replayed once.
+// only messages before the end offsets of the partitions at the start are replayed; messages that fail again are dead-lettered behind them
+// and are skipped if they are received anyway. the replay ends if every partition has reached its end offset
+// or no message is received for DeadLetterReplayIdleTime (the group has already consumed the remaining partitions)
+func ReplayDeadLetters(ctx context.Context, config configuration.Config) (count int, err error) {
+	deadLetterTopic := getDeadLetterTopic(config)
+	if deadLetterTopic == "" {
+		return 0, errors.New("no dead-letter topic configured")
+	}
+	connection, err := util.NewKafkaConnection(config)
+	if err != nil {
+		return 0, err
+	}
+	r := kafka.NewReader(kafka.ReaderConfig{
+		CommitInterval: 0, //synchronous commits
+		Brokers:        connection.Brokers,
+		Dialer:         connection.Dialer,
+		GroupID:        config.KafkaConsumerGroup + DeadLetterReplayGroupSuffix,
+		Topic:          deadLetterTopic,
+		MaxWait:        1 * time.Second,
+		Logger:         log.New(io.Discard, "", 0),
+		ErrorLogger:    log.New(os.Stderr, "[KAFKA-ERROR] ", log.LstdFlags),
+	})
+	defer r.Close()
+	writer := connection.NewWriter("")
+	defer writer.Close()
+	replayId := time.Now().UTC().Format(time.RFC3339Nano)
+	endOffsets, err := getEndOffsets(ctx, connection, deadLetterTopic)
+	if err != nil {
+		return 0, err
+	}
+	pending := map[int]bool{}
+	for partition, end := range endOffsets {
+		if end > 0 {
+			pending[partition] = true
+		}
+	}
+	for len(pending) > 0 {
+		fetchCtx, cancel := context.WithTimeout(ctx, DeadLetterReplayIdleTime)
+		m, err := r.FetchMessage(fetchCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		end := endOffsets[m.Partition]
+		if m.Offset >= end {
+			//dead-lettered after the start; left for the next replay
+			delete(pending, m.Partition)
+			continue
+		}
+		if m.Offset+1 >= end {
+			delete(pending, m.Partition)
+		}
+		if !isReplayedBy(m, replayId) {
+			err = writer.WriteMessages(ctx, createReplay(m, config.KafkaIncidentTopic, replayId))
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		err = r.CommitMessages(ctx, m)
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package consumer
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
+	"github.com/segmentio/kafka-go"
+	"testing"
+)
+
+func getHeader(msg kafka.Message, key string) (string, bool) {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value), true
+		}
+	}
+	return "", false
+}
+
+func TestDeadLetter(t *testing.T) {
+	msg := kafka.Message{Topic: "incidents", Partition: 2, Offset: 42, Key: []byte("key"), Value: []byte("{"), Headers: []kafka.Header{{Key: "trace", Value: []byte("t1")}}}
+
+	deadLetter := createDeadLetter(msg, "group", errors.Join(interfaces.ErrInvalidMessage, errors.New("unexpected end of JSON input")))
+	expected := map[string]string{
+		HeaderDeadLetterReason:  DeadLetterReasonInvalid,
+		HeaderOriginalTopic:     "incidents",
+		HeaderOriginalPartition: "2",
+		HeaderOriginalOffset:    "42",
+		HeaderOriginalGroup:     "group",
+		"trace":                 "t1",
+	}
+	for key, value := range expected {
+		if actual, _ := getHeader(deadLetter, key); actual != value {
+			t.Errorf("%v: %#v", key, actual)
+		}
+	}
+	if string(deadLetter.Key) != "key" || string(deadLetter.Value) != "{" || deadLetter.Topic != "" {
+		t.Errorf("%#v", deadLetter)
+	}
+
+	failing := createDeadLetter(msg, "group", errors.New("timeout"))
+	if reason, _ := getHeader(failing, HeaderDeadLetterReason); reason != DeadLetterReasonFailing {
+		t.Error(reason)
+	}
+
+	replay := createReplay(deadLetter, "default", "replay1")
+	if replay.Topic != "incidents" || string(replay.Value) != "{" || string(replay.Key) != "key" {
+		t.Errorf("%#v", replay)
+	}
+	if _, ok := getHeader(replay, HeaderDeadLetterError); ok {
+		t.Error("unexpected dead-letter header")
+	}
+	if _, ok := getHeader(replay, HeaderOriginalTopic); ok {
+		t.Error("unexpected original header")
+	}
+	if replayId, _ := getHeader(replay, HeaderDeadLetterReplayed); replayId != "replay1" {
+		t.Error("missing replay header")
+	}
+	if trace, _ := getHeader(replay, "trace"); trace != "t1" {
+		t.Error(trace)
+	}
+
+	//redelivered dead-letters don't accumulate headers
+	again := createDeadLetter(kafka.Message{Topic: "incidents", Headers: deadLetter.Headers}, "group", errors.New("timeout"))
+	if len(again.Headers) != len(deadLetter.Headers) {
+		t.Errorf("%#v", again.Headers)
+	}
+
+	//replayed messages that fail again are recognized by the replay
+	replayedAgain := createDeadLetter(kafka.Message{Topic: "incidents", Headers: replay.Headers}, "group", errors.New("timeout"))
+	if !isReplayedBy(replayedAgain, "replay1") || isReplayedBy(replayedAgain, "replay2") || isReplayedBy(deadLetter, "replay1") {
+		t.Errorf("%#v", replayedAgain.Headers)
+	}
+	if len(createReplay(replayedAgain, "default", "replay2").Headers) != len(replay.Headers) {
+		t.Errorf("%#v", replayedAgain.Headers)
+	}
+}
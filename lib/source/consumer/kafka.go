@@ -19,8 +19,13 @@ package consumer
 import (
 	"context"
 	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/source/util"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/tracing"
+	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"log"
 	"os"
@@ -28,37 +33,79 @@ import (
 	"time"
 )
 
-func RunConsumer(ctx context.Context, kafkaUrl string, groupid string, topic string, debug bool, topicConfigMap map[string][]kafka.ConfigEntry, listener func(topic string, msg []byte) error, errorhandler func(err error)) (err error) {
-	consumer := &Consumer{groupId: groupid, kafkaUrl: kafkaUrl, topic: topic, listener: listener, errorhandler: errorhandler, ctx: ctx, debug: debug, topicConfigMap: topicConfigMap}
+const DefaultRetryTimeout = 10 * time.Minute
+
+const (
+	ConsumeResultSuccess    = "success"
+	ConsumeResultDeadLetter = "dead_letter"
+	ConsumeResultDropped    = "dropped" //invalid message without dead-letter topic
+	ConsumeResultError      = "error"
+	ConsumeResultAborted    = "aborted" //shutdown while the message was retried; not committed
+)
+
+type Options struct {
+	DeadLetterTopic string
+	RetryTimeout    time.Duration
+	Workers         int                        //messages handled in parallel; defaults to 1
+	OrderingKey     func(msg []byte) string    //messages with the same key are handled in order; nil handles all messages in order
+	Metrics         interfaces.ConsumerMetrics //optional
+	Running         *sync.WaitGroup            //optional; Done is called after the consumer has stopped and its in-flight messages are finished and committed
+	Health          interfaces.HealthRegistry  //optional; the consumer registers a check of its group membership
+}
+
+// RunConsumer consumes topic; messages that fail for options.RetryTimeout or are invalid (interfaces.ErrInvalidMessage) are moved to options.DeadLetterTopic.
+// without dead-letter topic, invalid messages are dropped and failing messages are passed to the errorhandler.
+// offsets are committed up to the lowest unfinished message of each partition.
+// canceling ctx stops the intake; in-flight messages are finished and committed, failing messages are no longer retried
+func RunConsumer(ctx context.Context, connection util.KafkaConnection, groupid string, topic string, debug bool, topicSettings util.TopicSettings, options Options, listener func(ctx context.Context, topic string, msg []byte) error, errorhandler func(err error)) (err error) {
+	if options.RetryTimeout == 0 {
+		options.RetryTimeout = DefaultRetryTimeout
+	}
+	consumer := &Consumer{groupId: groupid, clientId: groupid + "-" + uuid.NewString(), connection: connection, topic: topic, listener: listener, errorhandler: errorhandler, ctx: ctx, debug: debug, topicSettings: topicSettings, options: options, committed: map[int]int64{}}
 	err = consumer.start()
 	return
 }
 
 type Consumer struct {
-	count          int
-	kafkaUrl       string
-	groupId        string
-	topic          string
-	ctx            context.Context
-	cancel         context.CancelFunc
-	listener       func(topic string, msg []byte) error
-	errorhandler   func(err error)
-	mux            sync.Mutex
-	debug          bool
-	topicConfigMap map[string][]kafka.ConfigEntry
+	count         int
+	connection    util.KafkaConnection
+	groupId       string
+	clientId      string //identifies the consumer in the group
+	topic         string
+	ctx           context.Context
+	cancel        context.CancelFunc
+	listener      func(ctx context.Context, topic string, msg []byte) error
+	errorhandler  func(err error)
+	mux           sync.Mutex
+	debug         bool
+	topicSettings util.TopicSettings
+	options       Options
+	deadLetters   *kafka.Writer
+	commitMux     sync.Mutex
+	committed     map[int]int64 //last committed offset per partition
 }
 
 func (this *Consumer) start() error {
 	if this.debug {
 		log.Println("DEBUG: consume topic: \"" + this.topic + "\"")
 	}
-	err := util.InitTopic(this.kafkaUrl, this.topicConfigMap, this.topic)
+	err := util.InitTopic(this.connection, this.topicSettings, this.topic)
 	if err != nil {
 		return err
 	}
+	if this.options.DeadLetterTopic != "" {
+		err = util.InitTopic(this.connection, this.topicSettings, this.options.DeadLetterTopic)
+		if err != nil {
+			return err
+		}
+		this.deadLetters = this.connection.NewWriter(this.options.DeadLetterTopic)
+	}
+	dialer := *this.connection.Dialer
+	dialer.ClientID = this.clientId
 	r := kafka.NewReader(kafka.ReaderConfig{
 		CommitInterval:         0, //synchronous commits
-		Brokers:                []string{this.kafkaUrl},
+		Brokers:                this.connection.Brokers,
+		Dialer:                 &dialer,
 		GroupID:                this.groupId,
 		Topic:                  this.topic,
 		MaxWait:                1 * time.Second,
@@ -67,8 +114,23 @@ func (this *Consumer) start() error {
 		WatchPartitionChanges:  true,
 		PartitionWatchInterval: time.Minute,
 	})
+	if this.options.Health != nil {
+		this.options.Health.Register("kafka-consumer:"+this.topic, this.checkGroupMembership)
+	}
+	scheduler := NewKeyedScheduler(this.options.Workers)
+	tracker := NewOffsetTracker()
+	if this.options.Running != nil {
+		this.options.Running.Add(1)
+	}
 	go func() {
+		if this.options.Running != nil {
+			defer this.options.Running.Done()
+		}
 		defer r.Close()
+		if this.deadLetters != nil {
+			defer this.deadLetters.Close()
+		}
+		defer scheduler.Wait()
 		defer log.Println("close consumer for topic ", this.topic)
 		for {
 			select {
@@ -84,38 +146,148 @@ func (this *Consumer) start() error {
 					this.errorhandler(err)
 					return
 				}
-
-				err = retry(func() error {
-					return this.listener(m.Topic, m.Value)
-				}, func(n int64) time.Duration {
-					return time.Duration(n) * time.Second
-				}, 10*time.Minute)
-
-				if err != nil {
-					log.Println("ERROR: unable to handle message (no commit)", err)
-					this.errorhandler(err)
-				} else {
-					err = r.CommitMessages(this.ctx, m)
+				if this.options.Metrics != nil {
+					this.options.Metrics.SetConsumerLag(m.Topic, m.Partition, m.HighWaterMark-m.Offset-1)
+				}
+				key := ""
+				if this.options.OrderingKey != nil {
+					key = this.options.OrderingKey(m.Value)
 				}
+				tracked := tracker.Add(m)
+				this.notifyInFlight(tracker)
+				scheduler.Schedule(key, func() {
+					start := time.Now()
+					result := this.handle(tracked.Message)
+					if result == ConsumeResultAborted {
+						//the message and all following messages of the partition are consumed again after the restart
+						return
+					}
+					if commit, ok := tracker.Done(tracked); ok {
+						this.commit(r, commit)
+					}
+					this.notifyInFlight(tracker)
+					if this.options.Metrics != nil {
+						this.options.Metrics.NotifyConsumedMessage(this.topic, result, time.Since(start))
+					}
+				})
 			}
 		}
 	}()
 	return err
 }
 
-func retry(f func() error, waitProvider func(n int64) time.Duration, timeout time.Duration) (err error) {
+// handle retries the listener and moves failing messages to the dead-letter topic; the message counts as finished in any case.
+// the message is traced in a span that continues the trace context of the message headers
+func (this *Consumer) handle(m kafka.Message) (result string) {
+	ctx, span := tracing.StartSpan(tracing.ExtractHeaders(context.Background(), tracing.KafkaHeaders{Headers: &m.Headers}), "consume "+m.Topic, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", m.Topic),
+		attribute.String("messaging.kafka.consumer.group", this.groupId),
+		attribute.Int("messaging.kafka.destination.partition", m.Partition),
+		attribute.Int64("messaging.kafka.message.offset", m.Offset),
+	))
+	var err error
+	defer func() {
+		span.SetAttributes(attribute.String("result", result))
+		tracing.EndSpan(span, err)
+	}()
+	err = retry(this.ctx, func() error {
+		return this.listener(ctx, m.Topic, m.Value)
+	}, func(n int64) time.Duration {
+		return time.Duration(n) * time.Second
+	}, this.options.RetryTimeout)
+
+	if err != nil && this.ctx.Err() != nil && !errors.Is(err, interfaces.ErrInvalidMessage) {
+		log.Println("WARNING: consumer stopped while message is retried (no commit)", err)
+		return ConsumeResultAborted
+	}
+	if err != nil && this.deadLetters != nil {
+		deadLetterErr := this.deadLetters.WriteMessages(context.WithoutCancel(this.ctx), createDeadLetter(m, this.groupId, err))
+		if deadLetterErr != nil {
+			log.Println("ERROR: unable to move message to dead-letter topic", deadLetterErr, err)
+			this.errorhandler(err)
+			return ConsumeResultError
+		}
+		log.Println("WARNING: message moved to dead-letter topic", this.options.DeadLetterTopic, err)
+		return ConsumeResultDeadLetter
+	}
+	if errors.Is(err, interfaces.ErrInvalidMessage) {
+		log.Println("ERROR: drop invalid message", err)
+		return ConsumeResultDropped
+	}
+	if err != nil {
+		log.Println("ERROR: unable to handle message", err)
+		this.errorhandler(err)
+		return ConsumeResultError
+	}
+	return ConsumeResultSuccess
+}
+
+// commit is serialized, so that a slow commit can not overwrite a later offset of the same partition
+func (this *Consumer) commit(r *kafka.Reader, m kafka.Message) {
+	this.commitMux.Lock()
+	defer this.commitMux.Unlock()
+	if last, ok := this.committed[m.Partition]; ok && last >= m.Offset {
+		return
+	}
+	err := r.CommitMessages(context.WithoutCancel(this.ctx), m) //in-flight messages are committed after the intake has been stopped
+	if err != nil {
+		log.Println("ERROR: unable to commit message", this.topic, m.Partition, m.Offset, err)
+		return
+	}
+	this.committed[m.Partition] = m.Offset
+}
+
+// checkGroupMembership returns an error until the consumer has joined its consumer group
+func (this *Consumer) checkGroupMembership(ctx context.Context) error {
+	resp, err := this.connection.NewClient().DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{this.groupId}})
+	if err != nil {
+		return err
+	}
+	for _, group := range resp.Groups {
+		if group.Error != nil {
+			return group.Error
+		}
+		for _, member := range group.Members {
+			if member.ClientID == this.clientId {
+				return nil
+			}
+		}
+	}
+	return errors.New("consumer is not a member of group " + this.groupId)
+}
+
+func (this *Consumer) notifyInFlight(tracker *OffsetTracker) {
+	if this.options.Metrics != nil {
+		this.options.Metrics.SetConsumerInFlight(this.topic, tracker.InFlight())
+	}
+}
+
+// retry calls f until it succeeds, the timeout is reached or ctx is done
+func retry(ctx context.Context, f func() error, waitProvider func(n int64) time.Duration, timeout time.Duration) (err error) {
 	err = errors.New("initial")
 	start := time.Now()
 	for i := int64(1); err != nil && time.Since(start) < timeout; i++ {
 		err = f()
+		if errors.Is(err, interfaces.ErrInvalidMessage) {
+			return err
+		}
 		if err != nil {
 			log.Println("ERROR: kafka listener error:", err)
 			wait := waitProvider(i)
-			if time.Since(start)+wait < timeout {
-				log.Println("ERROR: retry after:", wait.String())
-				time.Sleep(wait)
-			} else {
+			var unavailable interfaces.ShardUnavailableError
+			if errors.As(err, &unavailable) && time.Until(unavailable.RetryAt) > wait {
+				//on hold until the circuit breaker of the camunda shard allows calls again
+				wait = time.Until(unavailable.RetryAt)
+			}
+			if time.Since(start)+wait >= timeout {
+				return err
+			}
+			log.Println("ERROR: retry after:", wait.String())
+			select {
+			case <-ctx.Done():
 				return err
+			case <-time.After(wait):
 			}
 		}
 	}
@@ -17,6 +17,7 @@
 package listener
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 	"log"
@@ -26,14 +27,14 @@ func init() {
 	Factories = append(Factories, IncidentListenerFactory)
 }
 
-func IncidentListenerFactory(config configuration.Config, control interfaces.Controller) (topic string, listener Listener, err error) {
-	return config.KafkaIncidentTopic, func(msg []byte) (err error) {
+func IncidentListenerFactory(config configuration.Config, control interfaces.Controller) (topic string, listener Listener, orderingKey OrderingKey, err error) {
+	return config.KafkaIncidentTopic, func(ctx context.Context, msg []byte) (err error) {
 		defer func() {
 			if err != nil {
 				log.Printf("ERROR: %+v \n", err)
 			}
 		}()
-		err = control.HandleIncidentMessage(msg)
+		err = control.HandleIncidentMessage(ctx, msg)
 		return
-	}, nil
+	}, control.GetMessageOrderingKey, nil
 }
@@ -17,10 +17,15 @@
 package listener
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
 	"github.com/SENERGY-Platform/process-incident-worker/lib/interfaces"
 )
 
-type Listener func(msg []byte) (err error)
+// Listener handles msg; ctx carries the span of the message
+type Listener func(ctx context.Context, msg []byte) (err error)
 
-var Factories = []func(config configuration.Config, control interfaces.Controller) (topic string, listener Listener, err error){}
+// OrderingKey returns the key of a message; messages with the same key are handled in order, messages with an empty key are handled alone
+type OrderingKey func(msg []byte) string
+
+var Factories = []func(config configuration.Config, control interfaces.Controller) (topic string, listener Listener, orderingKey OrderingKey, err error){}
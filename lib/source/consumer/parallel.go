@@ -0,0 +1,133 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package consumer
+
+import (
+	"github.com/segmentio/kafka-go"
+	"sync"
+)
+
+// KeyedScheduler runs tasks with at most workers tasks in parallel.
+// tasks with the same key run in the order they are scheduled; tasks with an empty key run alone,
+// after all previously scheduled tasks are finished and before any following task is started
+type KeyedScheduler struct {
+	workers chan struct{} //one slot per unfinished task, queued tasks included
+	pending sync.WaitGroup
+	mux     sync.Mutex
+	queues  map[string][]func() //existing entry: a task with this key is running
+}
+
+func NewKeyedScheduler(workers int) *KeyedScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &KeyedScheduler{workers: make(chan struct{}, workers), queues: map[string][]func(){}}
+}
+
+// Schedule blocks while the count of running and queued tasks reaches the workers limit, so that the caller stops fetching new messages
+func (this *KeyedScheduler) Schedule(key string, task func()) {
+	if key == "" {
+		this.pending.Wait()
+		task()
+		return
+	}
+	this.pending.Add(1)
+	this.workers <- struct{}{}
+	this.mux.Lock()
+	if queue, running := this.queues[key]; running {
+		this.queues[key] = append(queue, task)
+		this.mux.Unlock()
+		return
+	}
+	this.queues[key] = []func(){}
+	this.mux.Unlock()
+	go func() {
+		for task != nil {
+			task()
+			<-this.workers
+			this.pending.Done()
+			task = this.next(key)
+		}
+	}()
+}
+
+func (this *KeyedScheduler) next(key string) (task func()) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	queue := this.queues[key]
+	if len(queue) == 0 {
+		delete(this.queues, key)
+		return nil
+	}
+	this.queues[key] = queue[1:]
+	return queue[0]
+}
+
+// Wait blocks until all scheduled tasks are finished
+func (this *KeyedScheduler) Wait() {
+	this.pending.Wait()
+}
+
+// OffsetTracker tracks the fetched messages per partition; a message may be committed if it and all previous messages of the partition are finished
+type OffsetTracker struct {
+	mux        sync.Mutex
+	partitions map[int][]*TrackedMessage
+}
+
+type TrackedMessage struct {
+	Message kafka.Message
+	done    bool
+}
+
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{partitions: map[int][]*TrackedMessage{}}
+}
+
+// Add must be called in the order the messages are fetched
+func (this *OffsetTracker) Add(msg kafka.Message) *TrackedMessage {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result := &TrackedMessage{Message: msg}
+	this.partitions[msg.Partition] = append(this.partitions[msg.Partition], result)
+	return result
+}
+
+// Done marks the message as finished and returns the message that may be committed; ok is false if a previous message is unfinished
+func (this *OffsetTracker) Done(msg *TrackedMessage) (commit kafka.Message, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	msg.done = true
+	list := this.partitions[msg.Message.Partition]
+	i := 0
+	for i < len(list) && list[i].done {
+		commit = list[i].Message
+		ok = true
+		i++
+	}
+	this.partitions[msg.Message.Partition] = list[i:]
+	return commit, ok
+}
+
+// InFlight returns the count of fetched messages that are unfinished or wait for the commit of a previous message
+func (this *OffsetTracker) InFlight() (count int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, list := range this.partitions {
+		count = count + len(list)
+	}
+	return count
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package consumer
+
+import (
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedSchedulerOrder(t *testing.T) {
+	scheduler := NewKeyedScheduler(4)
+	mux := sync.Mutex{}
+	handled := map[string][]int{}
+	for i := 0; i < 20; i++ {
+		key := []string{"a", "b", "c"}[i%3]
+		index := i
+		scheduler.Schedule(key, func() {
+			time.Sleep(time.Duration(20-index) * time.Millisecond) //later tasks are faster
+			mux.Lock()
+			defer mux.Unlock()
+			handled[key] = append(handled[key], index)
+		})
+	}
+	scheduler.Wait()
+	expected := map[string][]int{
+		"a": {0, 3, 6, 9, 12, 15, 18},
+		"b": {1, 4, 7, 10, 13, 16, 19},
+		"c": {2, 5, 8, 11, 14, 17},
+	}
+	if !reflect.DeepEqual(handled, expected) {
+		t.Errorf("%#v", handled)
+	}
+}
+
+func TestKeyedSchedulerParallel(t *testing.T) {
+	scheduler := NewKeyedScheduler(3)
+	running := atomic.Int64{}
+	maxRunning := atomic.Int64{}
+	for i := 0; i < 12; i++ {
+		scheduler.Schedule(string(rune('a'+i)), func() {
+			current := running.Add(1)
+			for {
+				old := maxRunning.Load()
+				if current <= old || maxRunning.CompareAndSwap(old, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+		})
+	}
+	scheduler.Wait()
+	if maxRunning.Load() != 3 {
+		t.Error(maxRunning.Load())
+	}
+}
+
+func TestKeyedSchedulerBarrier(t *testing.T) {
+	scheduler := NewKeyedScheduler(4)
+	finished := atomic.Int64{}
+	for i := 0; i < 4; i++ {
+		scheduler.Schedule(string(rune('a'+i)), func() {
+			time.Sleep(20 * time.Millisecond)
+			finished.Add(1)
+		})
+	}
+	scheduler.Schedule("", func() {
+		if finished.Load() != 4 {
+			t.Error("barrier started before previous tasks finished", finished.Load())
+		}
+		finished.Add(1)
+	})
+	scheduler.Schedule("a", func() {
+		if finished.Load() != 5 {
+			t.Error("task started before barrier finished", finished.Load())
+		}
+	})
+	scheduler.Wait()
+}
+
+func TestKeyedSchedulerQueueLimit(t *testing.T) {
+	scheduler := NewKeyedScheduler(2)
+	release := make(chan struct{})
+	scheduler.Schedule("a", func() { <-release })
+	scheduler.Schedule("a", func() {})
+	scheduled := make(chan struct{})
+	go func() {
+		//the queued task of "a" uses the second worker
+		scheduler.Schedule("b", func() {})
+		close(scheduled)
+	}()
+	select {
+	case <-scheduled:
+		t.Fatal("task scheduled while running and queued tasks reach the worker limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-scheduled:
+	case <-time.After(time.Second):
+		t.Fatal("task not scheduled after the running task finished")
+	}
+	scheduler.Wait()
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := NewOffsetTracker()
+	p0 := []*TrackedMessage{}
+	for i := int64(0); i < 3; i++ {
+		p0 = append(p0, tracker.Add(kafka.Message{Partition: 0, Offset: i}))
+	}
+	p1 := tracker.Add(kafka.Message{Partition: 1, Offset: 10})
+
+	if _, ok := tracker.Done(p0[1]); ok {
+		t.Error("offset 1 committable before offset 0 is finished")
+	}
+	if commit, ok := tracker.Done(p1); !ok || commit.Offset != 10 {
+		t.Error(ok, commit.Offset)
+	}
+	if tracker.InFlight() != 3 {
+		t.Error(tracker.InFlight())
+	}
+	if commit, ok := tracker.Done(p0[0]); !ok || commit.Offset != 1 {
+		t.Error(ok, commit.Offset)
+	}
+	if commit, ok := tracker.Done(p0[2]); !ok || commit.Offset != 2 {
+		t.Error(ok, commit.Offset)
+	}
+	if tracker.InFlight() != 0 {
+		t.Error(tracker.InFlight())
+	}
+}
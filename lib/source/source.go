@@ -27,6 +27,10 @@ type FactoryType struct{}
 
 var Factory = &FactoryType{}
 
-func (this *FactoryType) Start(ctx context.Context, config configuration.Config, control interfaces.Controller, runtimeErrorHandler func(err error)) (err error) {
-	return consumer.Start(ctx, config, control, runtimeErrorHandler)
+func (this *FactoryType) Start(ctx context.Context, config configuration.Config, control interfaces.Controller, options interfaces.SourceOptions, runtimeErrorHandler func(err error)) (err error) {
+	return consumer.Start(ctx, config, control, options, runtimeErrorHandler)
+}
+
+func (this *FactoryType) ReplayDeadLetters(ctx context.Context, config configuration.Config) (count int, err error) {
+	return consumer.ReplayDeadLetters(ctx, config)
 }
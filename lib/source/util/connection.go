@@ -0,0 +1,149 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SaslMechanismPlain       = "plain"
+	SaslMechanismScramSha256 = "scram-sha-256"
+	SaslMechanismScramSha512 = "scram-sha-512"
+)
+
+// KafkaConnection holds the bootstrap brokers and the TLS/SASL settings used by readers, writers and the topic-admin connection
+type KafkaConnection struct {
+	Brokers   []string
+	Dialer    *kafka.Dialer    //readers and topic-admin connections
+	Transport *kafka.Transport //writers
+}
+
+// NewKafkaConnection uses config.KafkaBrokers or, if empty, the comma separated config.KafkaUrl as bootstrap brokers
+func NewKafkaConnection(config configuration.Config) (result KafkaConnection, err error) {
+	tlsConfig, err := getTlsConfig(config)
+	if err != nil {
+		return result, err
+	}
+	mechanism, err := getSaslMechanism(config)
+	if err != nil {
+		return result, err
+	}
+	brokers := config.KafkaBrokers
+	if len(brokers) == 0 {
+		brokers = SplitBrokers(config.KafkaUrl)
+	}
+	return KafkaConnection{
+		Brokers: brokers,
+		Dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           tlsConfig,
+			SASLMechanism: mechanism,
+		},
+		Transport: &kafka.Transport{
+			TLS:  tlsConfig,
+			SASL: mechanism,
+		},
+	}, nil
+}
+
+// WithBrokers returns a copy of the connection with other brokers but the same TLS/SASL settings
+func (this KafkaConnection) WithBrokers(brokers ...string) KafkaConnection {
+	this.Brokers = brokers
+	return this
+}
+
+// NewWriter creates a hash-balanced writer; topic may be empty if the messages set their topic
+func (this KafkaConnection) NewWriter(topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(this.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
+		Transport:    this.Transport,
+	}
+}
+
+// NewClient creates a client for admin requests (e.g. topic configs)
+func (this KafkaConnection) NewClient() *kafka.Client {
+	return &kafka.Client{
+		Addr:      kafka.TCP(this.Brokers...),
+		Transport: this.Transport,
+		Timeout:   TopicAdminTimeout,
+	}
+}
+
+func SplitBrokers(brokers string) (result []string) {
+	for _, broker := range strings.Split(brokers, ",") {
+		broker = strings.TrimSpace(broker)
+		if broker != "" {
+			result = append(result, broker)
+		}
+	}
+	return result
+}
+
+func getTlsConfig(config configuration.Config) (*tls.Config, error) {
+	if !config.KafkaTls {
+		return nil, nil
+	}
+	result := &tls.Config{InsecureSkipVerify: config.KafkaTlsInsecureSkipVerify}
+	if config.KafkaTlsCaFile != "" {
+		ca, err := os.ReadFile(config.KafkaTlsCaFile)
+		if err != nil {
+			return nil, err
+		}
+		result.RootCAs = x509.NewCertPool()
+		if !result.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificate found in " + config.KafkaTlsCaFile)
+		}
+	}
+	if config.KafkaTlsCertFile != "" || config.KafkaTlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.KafkaTlsCertFile, config.KafkaTlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+	return result, nil
+}
+
+func getSaslMechanism(config configuration.Config) (sasl.Mechanism, error) {
+	switch strings.ToLower(config.KafkaSaslMechanism) {
+	case "", "-":
+		return nil, nil
+	case SaslMechanismPlain:
+		return plain.Mechanism{Username: config.KafkaSaslUser, Password: config.KafkaSaslPassword}, nil
+	case SaslMechanismScramSha256:
+		return scram.Mechanism(scram.SHA256, config.KafkaSaslUser, config.KafkaSaslPassword)
+	case SaslMechanismScramSha512:
+		return scram.Mechanism(scram.SHA512, config.KafkaSaslUser, config.KafkaSaslPassword)
+	default:
+		return nil, errors.New("unknown kafka sasl mechanism " + config.KafkaSaslMechanism)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"reflect"
+	"testing"
+)
+
+func TestNewKafkaConnection(t *testing.T) {
+	connection, err := NewKafkaConnection(configuration.Config{KafkaUrl: "kafka-1:9092, kafka-2:9092"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(connection.Brokers, []string{"kafka-1:9092", "kafka-2:9092"}) {
+		t.Error(connection.Brokers)
+	}
+	if connection.Dialer.TLS != nil || connection.Dialer.SASLMechanism != nil || connection.Transport.SASL != nil {
+		t.Error("unexpected tls or sasl settings")
+	}
+
+	connection, err = NewKafkaConnection(configuration.Config{KafkaUrl: "ignored:9092", KafkaBrokers: []string{"kafka:9093"}, KafkaTls: true, KafkaSaslMechanism: "SCRAM-SHA-512", KafkaSaslUser: "user", KafkaSaslPassword: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(connection.Brokers, []string{"kafka:9093"}) {
+		t.Error(connection.Brokers)
+	}
+	if connection.Dialer.TLS == nil || connection.Transport.TLS == nil {
+		t.Error("missing tls config")
+	}
+	if connection.Dialer.SASLMechanism == nil || connection.Dialer.SASLMechanism.Name() != "SCRAM-SHA-512" || connection.Transport.SASL == nil {
+		t.Error("missing sasl mechanism")
+	}
+
+	_, err = NewKafkaConnection(configuration.Config{KafkaSaslMechanism: "gssapi"})
+	if err == nil {
+		t.Error("expected error for unknown sasl mechanism")
+	}
+	_, err = NewKafkaConnection(configuration.Config{KafkaTls: true, KafkaTlsCaFile: "does-not-exist.pem"})
+	if err == nil {
+		t.Error("expected error for missing ca file")
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/segmentio/kafka-go"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	TopicInitModeCreate = "create" //create missing topics; existing topics are not changed
+	TopicInitModeUpdate = "update" //create missing topics and set the config entries of existing topics
+	TopicInitModeCheck  = "check"  //never create or change topics; fail if a topic is missing or differs from the expected settings
+)
+
+const TopicAdminTimeout = 30 * time.Second
+
+// TopicSettings are looked up by topic name or, if missing, by a prefix of the topic name
+type TopicSettings struct {
+	ConfigMap          map[string][]kafka.ConfigEntry
+	Partitions         map[string]int //defaults to 1 for created topics; only checked if set
+	ReplicationFactors map[string]int //defaults to 1 for created topics; only checked if set
+	Mode               string         //one of TopicInitMode...; defaults to TopicInitModeCreate
+}
+
+func NewTopicSettings(config configuration.Config) TopicSettings {
+	return TopicSettings{
+		ConfigMap:          config.TopicConfigMap,
+		Partitions:         config.TopicPartitions,
+		ReplicationFactors: config.TopicReplicationFactors,
+		Mode:               config.TopicInitMode,
+	}
+}
+
+func InitTopic(connection KafkaConnection, settings TopicSettings, topics ...string) (err error) {
+	switch settings.Mode {
+	case "", TopicInitModeCreate:
+		return CreateTopics(connection, settings, topics...)
+	case TopicInitModeUpdate:
+		err = CreateTopics(connection, settings, topics...)
+		if err != nil {
+			return err
+		}
+		return UpdateTopicConfigs(connection, settings, topics...)
+	case TopicInitModeCheck:
+		return CheckTopics(connection, settings, topics...)
+	default:
+		return errors.New("unknown topic init mode " + settings.Mode)
+	}
+}
+
+// CreateTopics creates missing topics with the configured partitions, replication factor and config entries
+func CreateTopics(connection KafkaConnection, settings TopicSettings, topics ...string) (err error) {
+	initConn, err := Dial(connection)
+	if err != nil {
+		return err
+	}
+	defer initConn.Close()
+
+	controller, err := initConn.Controller()
+	if err != nil {
+		return err
+	}
+	var controllerConn *kafka.Conn
+	controllerConn, err = connection.Dialer.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return err
+	}
+	defer controllerConn.Close()
+
+	for _, topic := range topics {
+		err = controllerConn.CreateTopics(kafka.TopicConfig{
+			Topic:             topic,
+			NumPartitions:     settings.getPartitions(topic),
+			ReplicationFactor: settings.getReplicationFactor(topic),
+			ConfigEntries:     GetTopicConfig(settings.ConfigMap, topic),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateTopicConfigs sets the config entries of existing topics (e.g. retention.ms); entries that are not configured are not changed
+func UpdateTopicConfigs(connection KafkaConnection, settings TopicSettings, topics ...string) error {
+	resources := []kafka.IncrementalAlterConfigsRequestResource{}
+	for _, topic := range topics {
+		configs := []kafka.IncrementalAlterConfigsRequestConfig{}
+		for _, entry := range GetTopicConfig(settings.ConfigMap, topic) {
+			configs = append(configs, kafka.IncrementalAlterConfigsRequestConfig{
+				Name:            entry.ConfigName,
+				Value:           entry.ConfigValue,
+				ConfigOperation: kafka.ConfigOperationSet,
+			})
+		}
+		if len(configs) > 0 {
+			resources = append(resources, kafka.IncrementalAlterConfigsRequestResource{
+				ResourceType: kafka.ResourceTypeTopic,
+				ResourceName: topic,
+				Configs:      configs,
+			})
+		}
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TopicAdminTimeout)
+	defer cancel()
+	resp, err := connection.NewClient().IncrementalAlterConfigs(ctx, &kafka.IncrementalAlterConfigsRequest{Resources: resources})
+	if err != nil {
+		return err
+	}
+	for _, resource := range resp.Resources {
+		if resource.Error != nil {
+			return errors.New("unable to update config of topic " + resource.ResourceName + ": " + resource.Error.Error())
+		}
+	}
+	return nil
+}
+
+// CheckTopics returns an error if a topic is missing or differs from the configured partitions, replication factor or config entries
+func CheckTopics(connection KafkaConnection, settings TopicSettings, topics ...string) error {
+	client := connection.NewClient()
+	ctx, cancel := context.WithTimeout(context.Background(), TopicAdminTimeout)
+	defer cancel()
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return err
+	}
+	found := map[string]kafka.Topic{}
+	for _, topic := range metadata.Topics {
+		found[topic.Name] = topic
+	}
+	errs := []error{}
+	describe := []kafka.DescribeConfigRequestResource{}
+	for _, name := range topics {
+		topic, ok := found[name]
+		if !ok || topic.Error != nil {
+			errs = append(errs, errors.New("topic "+name+" does not exist"))
+			continue
+		}
+		if expected, ok := getTopicValue(settings.Partitions, name); ok && len(topic.Partitions) != expected {
+			errs = append(errs, errors.New("topic "+name+" has "+strconv.Itoa(len(topic.Partitions))+" partitions, expected "+strconv.Itoa(expected)))
+		}
+		if expected, ok := getTopicValue(settings.ReplicationFactors, name); ok {
+			for _, partition := range topic.Partitions {
+				if len(partition.Replicas) != expected {
+					errs = append(errs, errors.New("topic "+name+" has "+strconv.Itoa(len(partition.Replicas))+" replicas, expected "+strconv.Itoa(expected)))
+					break
+				}
+			}
+		}
+		if entries := GetTopicConfig(settings.ConfigMap, name); len(entries) > 0 {
+			configNames := []string{}
+			for _, entry := range entries {
+				configNames = append(configNames, entry.ConfigName)
+			}
+			describe = append(describe, kafka.DescribeConfigRequestResource{ResourceType: kafka.ResourceTypeTopic, ResourceName: name, ConfigNames: configNames})
+		}
+	}
+	if len(describe) > 0 {
+		resp, err := client.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: describe})
+		if err != nil {
+			return err
+		}
+		for _, resource := range resp.Resources {
+			if resource.Error != nil {
+				errs = append(errs, errors.New("unable to read config of topic "+resource.ResourceName+": "+resource.Error.Error()))
+				continue
+			}
+			errs = append(errs, compareTopicConfig(resource, GetTopicConfig(settings.ConfigMap, resource.ResourceName))...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func compareTopicConfig(resource kafka.DescribeConfigResponseResource, expected []kafka.ConfigEntry) (errs []error) {
+	actual := map[string]string{}
+	for _, entry := range resource.ConfigEntries {
+		actual[entry.ConfigName] = entry.ConfigValue
+	}
+	for _, entry := range expected {
+		if value := actual[entry.ConfigName]; value != entry.ConfigValue {
+			errs = append(errs, errors.New("topic "+resource.ResourceName+" has "+entry.ConfigName+"="+value+", expected "+entry.ConfigValue))
+		}
+	}
+	return errs
+}
+
+func (this TopicSettings) getPartitions(topic string) int {
+	if result, ok := getTopicValue(this.Partitions, topic); ok && result > 0 {
+		return result
+	}
+	return 1
+}
+
+func (this TopicSettings) getReplicationFactor(topic string) int {
+	if result, ok := getTopicValue(this.ReplicationFactors, topic); ok && result > 0 {
+		return result
+	}
+	return 1
+}
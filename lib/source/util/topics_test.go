@@ -0,0 +1,68 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"github.com/segmentio/kafka-go"
+	"testing"
+)
+
+func TestTopicSettings(t *testing.T) {
+	settings := TopicSettings{
+		Partitions:         map[string]int{"incidents": 6, "dead-": 2},
+		ReplicationFactors: map[string]int{"incidents": 3},
+	}
+	cases := []struct {
+		topic       string
+		partitions  int
+		replication int
+	}{
+		{topic: "incidents", partitions: 6, replication: 3},
+		{topic: "dead-letters", partitions: 2, replication: 1},
+		{topic: "other", partitions: 1, replication: 1},
+	}
+	for _, c := range cases {
+		if actual := settings.getPartitions(c.topic); actual != c.partitions {
+			t.Error(c.topic, actual)
+		}
+		if actual := settings.getReplicationFactor(c.topic); actual != c.replication {
+			t.Error(c.topic, actual)
+		}
+	}
+	err := InitTopic(KafkaConnection{}, TopicSettings{Mode: "unknown"}, "incidents")
+	if err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestCompareTopicConfig(t *testing.T) {
+	resource := kafka.DescribeConfigResponseResource{
+		ResourceName: "incidents",
+		ConfigEntries: []kafka.DescribeConfigResponseConfigEntry{
+			{ConfigName: "retention.ms", ConfigValue: "86400000"},
+			{ConfigName: "cleanup.policy", ConfigValue: "delete"},
+		},
+	}
+	errs := compareTopicConfig(resource, []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "86400000"}})
+	if len(errs) != 0 {
+		t.Error(errs)
+	}
+	errs = compareTopicConfig(resource, []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "-1"}, {ConfigName: "cleanup.policy", ConfigValue: "compact"}})
+	if len(errs) != 2 {
+		t.Error(errs)
+	}
+}
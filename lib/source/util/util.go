@@ -17,12 +17,11 @@
 package util
 
 import (
+	"errors"
 	"github.com/segmentio/kafka-go"
 	"github.com/wvanbergen/kazoo-go"
 	"io/ioutil"
 	"log"
-	"net"
-	"strconv"
 	"strings"
 )
 
@@ -45,54 +44,36 @@ func getBroker(zkUrl string) (brokers []string, err error) {
 	}
 }
 
-func InitTopic(zkUrl string, configMap map[string][]kafka.ConfigEntry, topics ...string) (err error) {
-	return InitTopicWithConfig(zkUrl, configMap, 1, 1, topics...)
-}
-
-func InitTopicWithConfig(kafkaUrl string, configMap map[string][]kafka.ConfigEntry, numPartitions int, replicationFactor int, topics ...string) (err error) {
-	initConn, err := kafka.Dial("tcp", kafkaUrl)
-	if err != nil {
-		return err
-	}
-	defer initConn.Close()
-
-	controller, err := initConn.Controller()
-	if err != nil {
-		return err
-	}
-	var controllerConn *kafka.Conn
-	controllerConn, err = kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
-	if err != nil {
-		return err
-	}
-	defer controllerConn.Close()
-
-	for _, topic := range topics {
-		err = controllerConn.CreateTopics(kafka.TopicConfig{
-			Topic:             topic,
-			NumPartitions:     numPartitions,
-			ReplicationFactor: replicationFactor,
-			ConfigEntries:     GetTopicConfig(configMap, topic),
-		})
-		if err != nil {
-			return err
+// Dial connects to the first reachable bootstrap broker
+func Dial(connection KafkaConnection) (conn *kafka.Conn, err error) {
+	err = errors.New("no kafka broker configured")
+	for _, broker := range connection.Brokers {
+		conn, err = connection.Dialer.Dial("tcp", broker)
+		if err == nil {
+			return conn, nil
 		}
 	}
-	return nil
+	return nil, err
 }
 
 func GetTopicConfig(configMap map[string][]kafka.ConfigEntry, topic string) []kafka.ConfigEntry {
-	if configMap == nil {
-		return nil
+	result, _ := getTopicValue(configMap, topic)
+	return result
+}
+
+// getTopicValue returns the value of topic or, if missing, of a prefix of topic
+func getTopicValue[T any](m map[string]T, topic string) (result T, exists bool) {
+	if m == nil {
+		return result, false
 	}
-	result, exists := configMap[topic]
+	result, exists = m[topic]
 	if exists {
-		return result
+		return result, true
 	}
-	for key, conf := range configMap {
+	for key, value := range m {
 		if strings.HasPrefix(topic, key) {
-			return conf
+			return value, true
 		}
 	}
-	return nil
+	return result, false
 }
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaHeaders is a propagation.TextMapCarrier for the headers of kafka messages
+type KafkaHeaders struct {
+	Headers *[]kafka.Header
+}
+
+func (this KafkaHeaders) Get(key string) string {
+	for _, header := range *this.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (this KafkaHeaders) Set(key string, value string) {
+	for i, header := range *this.Headers {
+		if header.Key == key {
+			(*this.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*this.Headers = append(*this.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (this KafkaHeaders) Keys() (result []string) {
+	for _, header := range *this.Headers {
+		result = append(result, header.Key)
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds the "trace-id" of the context to records logged with ...Context(ctx, ...)
+type LogHandler struct {
+	slog.Handler
+}
+
+func NewLogHandler(handler slog.Handler) *LogHandler {
+	return &LogHandler{Handler: handler}
+}
+
+func (this *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if traceId := TraceId(ctx); traceId != "" {
+		record.AddAttrs(slog.String("trace-id", traceId))
+	}
+	return this.Handler.Handle(ctx, record)
+}
+
+func (this *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: this.Handler.WithAttrs(attrs)}
+}
+
+func (this *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: this.Handler.WithGroup(name)}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"os"
+	"time"
+)
+
+const ServiceName = "process-incident-worker"
+
+const (
+	ExporterOtlp   = "otlp"   //otlp over http to TracingOtlpEndpoint
+	ExporterStdout = "stdout" //pretty printed json spans for local debugging
+	ExporterFile   = "file"   //json spans appended to TracingFile
+)
+
+const ShutdownTimeout = 10 * time.Second
+
+// DefaultSampleRatio is used if TracingSampleRatio is not set (0)
+const DefaultSampleRatio = 1.0
+
+var tracer = otel.Tracer("github.com/SENERGY-Platform/process-incident-worker")
+
+type Tracing struct {
+	provider *sdktrace.TracerProvider
+	file     *os.File
+}
+
+// New sets the global tracer provider and the w3c trace-context propagator.
+// without exporter ("" or "-") spans are only propagated, not recorded
+func New(config configuration.Config) (result *Tracing, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.TracingExporter == "" || config.TracingExporter == "-" {
+		return &Tracing{}, nil
+	}
+	sampleRatio, err := getSampleRatio(config)
+	if err != nil {
+		return nil, err
+	}
+	result = &Tracing{}
+	var exporter sdktrace.SpanExporter
+	switch config.TracingExporter {
+	case ExporterOtlp:
+		options := []otlptracehttp.Option{}
+		if config.TracingOtlpEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.TracingOtlpEndpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		if config.TracingFile == "" {
+			return nil, errors.New("missing tracing_file for tracing exporter " + ExporterFile)
+		}
+		result.file, err = os.OpenFile(config.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(result.file))
+	default:
+		return nil, errors.New("unknown tracing exporter: " + config.TracingExporter)
+	}
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", ServiceName)))
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(result.provider)
+	log.Println("tracing with exporter", config.TracingExporter)
+	return result, nil
+}
+
+func getSampleRatio(config configuration.Config) (float64, error) {
+	if config.TracingSampleRatio == 0 {
+		return DefaultSampleRatio, nil
+	}
+	if config.TracingSampleRatio < 0 || config.TracingSampleRatio > 1 {
+		return 0, errors.New("tracing_sample_ratio must be between 0 and 1")
+	}
+	return config.TracingSampleRatio, nil
+}
+
+// Close flushes the recorded spans
+func (this *Tracing) Close() (err error) {
+	if this == nil {
+		return nil
+	}
+	if this.provider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		err = this.provider.Shutdown(ctx)
+	}
+	if this.file != nil {
+		err = errors.Join(err, this.file.Close())
+	}
+	return err
+}
+
+func StartSpan(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, options...)
+}
+
+// EndSpan records err and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceId returns the trace id of the span in ctx or "" if ctx contains no valid span
+func TraceId(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// InjectHeaders writes the trace context of ctx to carrier (e.g. propagation.HeaderCarrier for http requests)
+func InjectHeaders(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// ExtractHeaders continues the trace context found in carrier
+func ExtractHeaders(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/process-incident-worker/lib/configuration"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"log/slog"
+	"testing"
+)
+
+func TestKafkaHeaderPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	producerCtx, producerSpan := StartSpan(context.Background(), "produce")
+	headers := []kafka.Header{{Key: "other", Value: []byte("value")}}
+	InjectHeaders(producerCtx, KafkaHeaders{Headers: &headers})
+	producerSpan.End()
+	if len(headers) != 2 || headers[1].Key != "traceparent" {
+		t.Fatalf("%#v", headers)
+	}
+
+	consumerCtx, consumerSpan := StartSpan(ExtractHeaders(context.Background(), KafkaHeaders{Headers: &headers}), "consume")
+	EndSpan(consumerSpan, nil)
+	if TraceId(consumerCtx) == "" || TraceId(consumerCtx) != TraceId(producerCtx) {
+		t.Fatal(TraceId(consumerCtx), TraceId(producerCtx))
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() {
+		t.Fatal(spans)
+	}
+	if TraceId(context.Background()) != "" {
+		t.Fatal(TraceId(context.Background()))
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(buf, nil))).With("module", "test")
+
+	ctx, span := StartSpan(context.Background(), "test")
+	defer span.End()
+	logger.InfoContext(ctx, "with span")
+	logger.Info("without span")
+
+	decoder := json.NewDecoder(buf)
+	withSpan := map[string]interface{}{}
+	withoutSpan := map[string]interface{}{}
+	if err := decoder.Decode(&withSpan); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoder.Decode(&withoutSpan); err != nil {
+		t.Fatal(err)
+	}
+	if withSpan["trace-id"] != TraceId(ctx) || withSpan["module"] != "test" {
+		t.Fatal(withSpan)
+	}
+	if _, ok := withoutSpan["trace-id"]; ok {
+		t.Fatal(withoutSpan)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	ratio, err := getSampleRatio(configuration.Config{})
+	if err != nil || ratio != DefaultSampleRatio {
+		t.Fatal(ratio, err)
+	}
+	ratio, err = getSampleRatio(configuration.Config{TracingSampleRatio: 0.25})
+	if err != nil || ratio != 0.25 {
+		t.Fatal(ratio, err)
+	}
+	_, err = getSampleRatio(configuration.Config{TracingSampleRatio: 2})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -37,7 +37,7 @@ func main() {
 		log.Fatalf("FATAL: %+v", err)
 	}
 
-	err = lib.Start(context.Background(), config)
+	handle, err := lib.Start(context.Background(), config)
 	if err != nil {
 		log.Fatalf("FATAL: %+v", err)
 	}
@@ -46,4 +46,9 @@ func main() {
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
 	sig := <-shutdown
 	log.Println("received shutdown signal", sig)
+	err = handle.Stop()
+	if err != nil {
+		log.Println("ERROR: graceful shutdown failed:", err)
+		os.Exit(1)
+	}
 }
@@ -49,7 +49,7 @@ func TestDatabaseDeprecated(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -99,7 +99,7 @@ func TestDatabase(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -149,7 +149,7 @@ func TestCamunda(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -219,7 +219,7 @@ func TestCamundaDeprecated(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -289,7 +289,7 @@ func TestDeleteByDeploymentIdDeprecated(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -381,7 +381,7 @@ func TestDeleteByDeploymentId(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -486,7 +486,7 @@ func TestDeleteByInstanceIdDeprecated(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -578,7 +578,7 @@ func TestDeleteByInstanceId(t *testing.T) {
 		return
 	}
 
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
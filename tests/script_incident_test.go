@@ -65,7 +65,7 @@ func TestScriptIncident(t *testing.T) {
 	config.NotificationUrl = notificationTestServer.URL
 
 	log.Println("start lib")
-	err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
+	_, err = lib.StartWith(ctx, config, source.Factory, camunda.Factory, database.Factory, func(err error) {
 		t.Errorf("ERROR: %+v", err)
 	})
 	if err != nil {
@@ -117,7 +117,7 @@ func TestScriptIncident(t *testing.T) {
 			t.Error(err)
 			return
 		}
-		err = c.StartProcess(processId, "testuser")
+		_, err = c.StartProcess(ctx, processId, "testuser", nil)
 		if err != nil {
 			t.Error(err)
 			return